@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+// readVec3 アクセサが指す VEC3 (float) のデータを読み取る
+func readVec3(doc *gltf.Document, accessor uint32) ([]vector, error) {
+	a := doc.Accessors[accessor]
+	if a.Type != gltf.AccessorVec3 || a.ComponentType != gltf.ComponentFloat || a.BufferView == nil {
+		return nil, fmt.Errorf("accessor %d is not a float VEC3", accessor)
+	}
+
+	view := doc.BufferViews[*a.BufferView]
+	data := doc.Buffers[view.Buffer].Data
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = 12
+	}
+
+	values := make([]vector, a.Count)
+	for i := range values {
+		offset := view.ByteOffset + a.ByteOffset + uint32(i)*stride
+		if int(offset)+12 > len(data) {
+			return nil, fmt.Errorf("accessor %d is out of buffer range", accessor)
+		}
+		for j := range values[i] {
+			bits := binary.LittleEndian.Uint32(data[offset+uint32(j)*4:])
+			values[i][j] = float64(math.Float32frombits(bits))
+		}
+	}
+
+	return values, nil
+}
+
+// readIndices アクセサが指すインデックス (unsigned byte / short / int) のデータを読み取る
+func readIndices(doc *gltf.Document, accessor uint32) ([]uint32, error) {
+	a := doc.Accessors[accessor]
+	if a.Type != gltf.AccessorScalar || a.BufferView == nil {
+		return nil, fmt.Errorf("accessor %d is not a SCALAR", accessor)
+	}
+
+	var size uint32
+	switch a.ComponentType {
+	case gltf.ComponentUbyte:
+		size = 1
+	case gltf.ComponentUshort:
+		size = 2
+	case gltf.ComponentUint:
+		size = 4
+	default:
+		return nil, fmt.Errorf("accessor %d is not an unsigned integer", accessor)
+	}
+
+	view := doc.BufferViews[*a.BufferView]
+	data := doc.Buffers[view.Buffer].Data
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = size
+	}
+
+	values := make([]uint32, a.Count)
+	for i := range values {
+		offset := view.ByteOffset + a.ByteOffset + uint32(i)*stride
+		if int(offset+size) > len(data) {
+			return nil, fmt.Errorf("accessor %d is out of buffer range", accessor)
+		}
+		switch size {
+		case 1:
+			values[i] = uint32(data[offset])
+		case 2:
+			values[i] = uint32(binary.LittleEndian.Uint16(data[offset:]))
+		default:
+			values[i] = binary.LittleEndian.Uint32(data[offset:])
+		}
+	}
+
+	return values, nil
+}
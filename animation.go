@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	easingLinear    = "linear"
+	easingEaseIn    = "ease-in"
+	easingEaseOut   = "ease-out"
+	easingEaseInOut = "ease-in-out"
+)
+
+const (
+	defaultMoveDuration = 0.5
+	maxMoveDuration     = 10.0
+	maxMovePause        = 10.0
+	// animationSamples イージングを表すための 1 手あたりのキーフレームの区間数
+	animationSamples = 12
+)
+
+// animationOptions 回転記号を再生するアニメーションの設定
+type animationOptions struct {
+	Duration float64 // 1 手の時間 (秒)
+	Pause    float64 // 手と手の間の停止時間 (秒)
+	Easing   string
+	// Timestamps 手順のインデックスと、その手の開始時刻 (秒)。指定の無い手は前の手の終了から Pause 後に始まる
+	Timestamps map[int]float64
+}
+
+// easings イージングの名前と、進行度 (0 - 1) を回転量の割合に変換する関数
+var easings = map[string]func(float64) float64{
+	easingLinear: func(t float64) float64 { return t },
+	easingEaseIn: func(t float64) float64 { return t * t * t },
+	easingEaseOut: func(t float64) float64 {
+		return 1 - (1-t)*(1-t)*(1-t)
+	},
+	easingEaseInOut: func(t float64) float64 {
+		if t < 0.5 {
+			return 4 * t * t * t
+		}
+		return 1 - 4*(1-t)*(1-t)*(1-t)
+	},
+}
+
+// degreeFaces Degree の面 (U D F B L R の順) と Face の対応
+var degreeFaces = [6]Face{FaceU, FaceD, FaceF, FaceB, FaceL, FaceR}
+
+// generateAnimatedCube 完成状態から回転記号を順に再生する glTF のアニメーションを付けたキューブを生成する
+func generateAnimatedCube(algorithm []string, options animationOptions, style cubeStyle) ([]byte, error) {
+	var doc gltf.Document
+
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		return nil, err
+	}
+	if err := style.apply(&doc); err != nil {
+		return nil, err
+	}
+
+	degrees, err := parseAlg(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	times, rotations, err := animationKeyframes(doc.Nodes, degrees, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// 離したピースは回転に合わせて translation も動かす
+	var translations [][][3]float64
+	if style.Explode > 0 {
+		e, err := newExploder(style.Explode)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.apply(doc.Nodes); err != nil {
+			return nil, err
+		}
+		translations = make([][][3]float64, len(doc.Nodes))
+		for i, node := range doc.Nodes {
+			for _, r := range rotations[i] {
+				offset, err := e.offset(node.Name, r)
+				if err != nil {
+					return nil, err
+				}
+				translations[i] = append(translations[i], offset)
+			}
+		}
+	}
+
+	if len(degrees) > 0 {
+		addNodeAnimation(&doc, strings.Join(algorithm, " "), times, rotations, translations)
+	}
+
+	style.hide(&doc)
+	lighting, err := style.addLighting(&doc, true)
+	if err != nil {
+		return nil, err
+	}
+	if style.View != nil {
+		if err := addCameraView(&doc, style.View, lighting); err != nil {
+			return nil, err
+		}
+	}
+	if style.Describe != "" {
+		if err := describeAsset(&doc, algorithm, style); err != nil {
+			return nil, err
+		}
+	}
+	return encodeGltf(&doc)
+}
+
+// generateCubeAt 回転記号を moves 手目まで適用したキューブを生成する。
+// 小数部分は次の手の途中で、例えば 3.5 は 4 手目を半分だけ回した状態になる
+func generateCubeAt(algorithm []string, moves float64, style cubeStyle) ([]byte, error) {
+	var doc gltf.Document
+
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		return nil, err
+	}
+	if err := style.apply(&doc); err != nil {
+		return nil, err
+	}
+
+	nodes, err := applyAlgAt(doc.Nodes, algorithm, moves)
+	if err != nil {
+		return nil, err
+	}
+	doc.Nodes = nodes
+	if err := explodeNodes(doc.Nodes, style.Explode); err != nil {
+		return nil, err
+	}
+
+	style.hide(&doc)
+	lighting, err := style.addLighting(&doc, false)
+	if err != nil {
+		return nil, err
+	}
+	if style.View != nil {
+		if err := addCameraView(&doc, style.View, lighting); err != nil {
+			return nil, err
+		}
+	}
+	// 回しかけの手は含めずに説明する
+	applied := algorithm
+	if n := int(moves); n < len(applied) {
+		applied = applied[:n]
+	}
+	if style.Describe != "" {
+		if err := describeAsset(&doc, applied, style); err != nil {
+			return nil, err
+		}
+	}
+	return encodeGltf(&doc)
+}
+
+// applyAlgAt ノードに回転記号を moves 手目まで適用し、回転後のノードを引数と同じ順で取得する。
+// 回転途中の層は、その手の回転を球面線形補間した向きになる。引数のノードは変更しない
+func applyAlgAt(nodes []*gltf.Node, algorithm []string, moves float64) ([]*gltf.Node, error) {
+	degrees, err := parseAlg(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if moves < 0 || moves > float64(len(degrees)) {
+		return nil, fmt.Errorf("moves must be between 0 and %d", len(degrees))
+	}
+
+	// 1 手を 1 秒として線形に回すと、時刻がそのまま手数になる
+	times, rotations, err := animationKeyframes(nodes, degrees, animationOptions{Duration: 1, Easing: easingLinear})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*gltf.Node, len(nodes))
+	for i, node := range nodes {
+		n := *node
+		n.Rotation = sampleKeyframes(times, rotations[i], moves)
+		result[i] = &n
+	}
+	return result, nil
+}
+
+// animationKeyframes 各ノードの rotation のキーフレームを求める。
+// 回転の途中はイージングに合わせて animationSamples 区間に分け、線形補間 (球面線形補間) で再生する
+func animationKeyframes(nodes []*gltf.Node, degrees []Degree, options animationOptions) ([]float64, [][][4]float64, error) {
+	ease, ok := easings[options.Easing]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown easing: %s", options.Easing)
+	}
+
+	def, err := nodeToDefinition(nodes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	times := []float64{0}
+	rotations := make([][][4]float64, len(nodes))
+	for i, node := range nodes {
+		rotations[i] = [][4]float64{node.RotationOrDefault()}
+	}
+
+	starts, err := moveStarts(len(degrees), options)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, degree := range degrees {
+		start := starts[i]
+
+		// 回す前と回した後の向きは applyAlg と同じ rotate で求め、その間を補間する。
+		// 180 度の回転は補間の向きが定まらないため、同じ向きに 90 度回した向きを経由する
+		next, half := *def, *def
+		rotate(&next, degree)
+		before, after, middle := nodeRotations(def.nodes()), nodeRotations(next.nodes()), map[string][4]float64(nil)
+		if degree >= rotateU2 {
+			rotate(&half, degree-rotateU2+rotateRightU)
+			middle = nodeRotations(half.nodes())
+		}
+
+		for k := 0; k <= animationSamples; k++ {
+			time := start + options.Duration*float64(k)/animationSamples
+			if k == 0 && time <= times[len(times)-1] {
+				continue
+			}
+			times = append(times, time)
+
+			progress := ease(float64(k) / animationSamples)
+			for j, node := range nodes {
+				a, b := before[node.Name], after[node.Name]
+				r := a
+				switch {
+				case a == b:
+				case middle == nil:
+					r = slerp(a, b, progress)
+				case progress < 0.5:
+					r = slerp(a, middle[node.Name], progress*2)
+				default:
+					r = slerp(middle[node.Name], b, progress*2-1)
+				}
+				// 補間が遠回りにならないように、直前のキーフレームと同じ半球に揃える
+				last := rotations[j][len(rotations[j])-1]
+				if last[0]*r[0]+last[1]*r[1]+last[2]*r[2]+last[3]*r[3] < 0 {
+					r = [4]float64{-r[0], -r[1], -r[2], -r[3]}
+				}
+				rotations[j] = append(rotations[j], r)
+			}
+		}
+
+		def = &next
+	}
+
+	return times, rotations, nil
+}
+
+// moveStarts count 手の各手の開始時刻 (秒) を求める。
+// 指定の無い手は前の手の終了から Pause 後に始まり、前の手が終わる前に始まる時刻が指定された場合はエラーにする
+func moveStarts(count int, options animationOptions) ([]float64, error) {
+	starts := make([]float64, count)
+	end := 0.0
+	for i := range starts {
+		start := end
+		if i > 0 {
+			start += options.Pause
+		}
+		if t, ok := options.Timestamps[i]; ok {
+			if t < end-1e-9 {
+				return nil, fmt.Errorf("move %d starts at %gs before the previous move ends at %gs", i+1, t, end)
+			}
+			start = t
+		}
+		starts[i] = start
+		end = start + options.Duration
+	}
+	return starts, nil
+}
+
+// addNodeAnimation キーフレームをバッファーに追加し、ノードごとの rotation のチャンネルを持つアニメーションを追加する。
+// translations が nil でない場合は translation のチャンネルも追加する
+func addNodeAnimation(doc *gltf.Document, name string, times []float64, rotations [][][4]float64, translations [][][3]float64) {
+	data := new(bytes.Buffer)
+	for _, t := range times {
+		_ = binary.Write(data, binary.LittleEndian, float32(t))
+	}
+	timesLength := uint32(data.Len())
+	for _, keyframes := range rotations {
+		for _, r := range keyframes {
+			_ = binary.Write(data, binary.LittleEndian, [4]float32{float32(r[0]), float32(r[1]), float32(r[2]), float32(r[3])})
+		}
+	}
+	rotationsLength := uint32(data.Len()) - timesLength
+	for _, keyframes := range translations {
+		for _, t := range keyframes {
+			_ = binary.Write(data, binary.LittleEndian, [3]float32{float32(t[0]), float32(t[1]), float32(t[2])})
+		}
+	}
+
+	buffer := &gltf.Buffer{ByteLength: uint32(data.Len()), Data: data.Bytes()}
+	buffer.EmbeddedResource()
+	doc.Buffers = append(doc.Buffers, buffer)
+	bufferIndex := uint32(len(doc.Buffers) - 1)
+
+	doc.BufferViews = append(doc.BufferViews,
+		&gltf.BufferView{Buffer: bufferIndex, ByteLength: timesLength},
+		&gltf.BufferView{Buffer: bufferIndex, ByteOffset: timesLength, ByteLength: rotationsLength},
+	)
+	timesView, rotationsView := uint32(len(doc.BufferViews)-2), uint32(len(doc.BufferViews)-1)
+	translationsView := uint32(0)
+	if translations != nil {
+		doc.BufferViews = append(doc.BufferViews, &gltf.BufferView{
+			Buffer: bufferIndex, ByteOffset: timesLength + rotationsLength, ByteLength: uint32(data.Len()) - timesLength - rotationsLength,
+		})
+		translationsView = uint32(len(doc.BufferViews) - 1)
+	}
+
+	doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+		BufferView:    gltf.Index(timesView),
+		ComponentType: gltf.ComponentFloat,
+		Count:         uint32(len(times)),
+		Type:          gltf.AccessorScalar,
+		Min:           []float64{times[0]},
+		Max:           []float64{times[len(times)-1]},
+	})
+	input := uint32(len(doc.Accessors) - 1)
+
+	animation := &gltf.Animation{Name: name}
+	for i, keyframes := range rotations {
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView:    gltf.Index(rotationsView),
+			ByteOffset:    uint32(i * len(keyframes) * 16),
+			ComponentType: gltf.ComponentFloat,
+			Count:         uint32(len(keyframes)),
+			Type:          gltf.AccessorVec4,
+		})
+
+		animation.Samplers = append(animation.Samplers, &gltf.AnimationSampler{
+			Input:         gltf.Index(input),
+			Interpolation: gltf.InterpolationLinear,
+			Output:        gltf.Index(uint32(len(doc.Accessors) - 1)),
+		})
+		animation.Channels = append(animation.Channels, &gltf.Channel{
+			Sampler: gltf.Index(uint32(len(animation.Samplers) - 1)),
+			Target:  gltf.ChannelTarget{Node: gltf.Index(uint32(i)), Path: gltf.TRSRotation},
+		})
+	}
+	for i, keyframes := range translations {
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView:    gltf.Index(translationsView),
+			ByteOffset:    uint32(i * len(keyframes) * 12),
+			ComponentType: gltf.ComponentFloat,
+			Count:         uint32(len(keyframes)),
+			Type:          gltf.AccessorVec3,
+		})
+
+		animation.Samplers = append(animation.Samplers, &gltf.AnimationSampler{
+			Input:         gltf.Index(input),
+			Interpolation: gltf.InterpolationLinear,
+			Output:        gltf.Index(uint32(len(doc.Accessors) - 1)),
+		})
+		animation.Channels = append(animation.Channels, &gltf.Channel{
+			Sampler: gltf.Index(uint32(len(animation.Samplers) - 1)),
+			Target:  gltf.ChannelTarget{Node: gltf.Index(uint32(i)), Path: gltf.TRSTranslation},
+		})
+	}
+	doc.Animations = append(doc.Animations, animation)
+}
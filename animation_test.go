@@ -0,0 +1,161 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestAnimationKeyframes(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		alg     string
+		options animationOptions
+		// 最後のキーフレームの時刻
+		end float64
+	}{
+		{
+			name:    "sexy move",
+			alg:     "R U R' U'",
+			options: animationOptions{Duration: 0.5, Easing: easingEaseInOut},
+			end:     2,
+		},
+		{
+			name:    "half turns with pause",
+			alg:     "F2 B2 L2 R2 U2 D2",
+			options: animationOptions{Duration: 1, Pause: 0.5, Easing: easingLinear},
+			end:     8.5,
+		},
+		{
+			name: "timestamps",
+			alg:  "D L' B",
+			options: animationOptions{Duration: 0.25, Easing: easingEaseOut,
+				Timestamps: map[int]float64{1: 1, 2: 1.25}},
+			end: 1.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alg := strings.Fields(tt.alg)
+			degrees, err := parseAlg(alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			times, rotations, err := animationKeyframes(gltfDoc.Nodes, degrees, tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := times[len(times)-1]; math.Abs(got-tt.end) > 1e-9 {
+				t.Errorf("end must be %g, actual: %g", tt.end, got)
+			}
+			for i := 1; i < len(times); i++ {
+				if times[i] <= times[i-1] {
+					t.Fatalf("times are not increasing at %d: %v", i, times)
+				}
+			}
+
+			// 最後のキーフレームは回転記号を適用したノードと同じ向きになる
+			nodes, err := applyAlg(gltfDoc.Nodes, alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := nodeRotations(nodes)
+			for i, node := range gltfDoc.Nodes {
+				got := rotations[i][len(rotations[i])-1]
+				w := want[node.Name]
+				if d := got[0]*w[0] + got[1]*w[1] + got[2]*w[2] + got[3]*w[3]; math.Abs(math.Abs(d)-1) > 1e-6 {
+					t.Errorf("rotation of %s must be %v, actual: %v", node.Name, w, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAnimationKeyframesOverlap(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	degrees, err := parseAlg([]string{"R", "U"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	options := animationOptions{Duration: 1, Easing: easingLinear, Timestamps: map[int]float64{1: 0.5}}
+	if _, _, err := animationKeyframes(gltfDoc.Nodes, degrees, options); err == nil {
+		t.Error("overlapping timestamps must be an error")
+	}
+}
+
+func TestApplyAlgAt(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	alg := []string{"R", "U2", "F'"}
+
+	// 途中でなければ applyAlg と同じ向きになる
+	nodes, err := applyAlgAt(gltfDoc.Nodes, alg, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := applyAlg(gltfDoc.Nodes, alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := nodeRotations(applied)
+	for _, node := range nodes {
+		got, w := node.RotationOrDefault(), want[node.Name]
+		if d := got[0]*w[0] + got[1]*w[1] + got[2]*w[2] + got[3]*w[3]; math.Abs(math.Abs(d)-1) > 1e-6 {
+			t.Errorf("rotation of %s must be %v, actual: %v", node.Name, w, got)
+		}
+	}
+
+	// 0.5 手目は R 面だけが 45 度回っている
+	nodes, err = applyAlgAt(gltfDoc.Nodes, alg, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, node := range nodes {
+		home, err := nodeHome(node.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, initial := node.RotationOrDefault(), gltfDoc.Nodes[i].RotationOrDefault()
+		d := math.Abs(got[0]*initial[0] + got[1]*initial[1] + got[2]*initial[2] + got[3]*initial[3])
+		wantAngle := 0.0
+		if home[0] == 1 {
+			wantAngle = math.Pi / 4
+		}
+		if angle := 2 * math.Acos(math.Min(d, 1)); math.Abs(angle-wantAngle) > 1e-6 {
+			t.Errorf("%s must be turned %g rad, actual: %g rad", node.Name, wantAngle, angle)
+		}
+	}
+
+	// 180 度の回転の半分は、同じ面を 90 度回した向きと同じになる
+	nodes, err = applyAlgAt(gltfDoc.Nodes, alg, 1.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err = applyAlg(gltfDoc.Nodes, []string{"R", "U"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = nodeRotations(applied)
+	for _, node := range nodes {
+		got, w := node.RotationOrDefault(), want[node.Name]
+		if d := got[0]*w[0] + got[1]*w[1] + got[2]*w[2] + got[3]*w[3]; math.Abs(math.Abs(d)-1) > 1e-6 {
+			t.Errorf("%s rotation must be %v at U2 halfway, actual: %v", node.Name, w, got)
+		}
+	}
+
+	if _, err := applyAlgAt(gltfDoc.Nodes, alg, 3.5); err == nil {
+		t.Error("moves beyond the algorithm must be an error")
+	}
+}
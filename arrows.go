@@ -0,0 +1,228 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	arrowsAuto = "auto"
+	// arrowSegments 曲線の矢印を折れ線にするときの分割数
+	arrowSegments = 16
+)
+
+// arrowColor 矢印の色。VisualCube の arw の既定の色に合わせる
+var arrowColor = colorCodes['d']
+
+// arrowPath 3D の矢印の軸。Ups は各点で矢印の面が向く方向
+type arrowPath struct {
+	Points []vector
+	Ups    []vector
+}
+
+// arrowGeometry ピースの大きさに合わせた矢印の寸法
+type arrowGeometry struct {
+	half, cell, lift                float64
+	width, headLength, headWidth    float64
+	margin, bend, twistRadius, turn float64
+}
+
+// newArrowGeometry cube.gltf の大きさ (1 辺 extent) から矢印の寸法を決める
+func newArrowGeometry(extent float64) arrowGeometry {
+	cell := extent / 3
+	return arrowGeometry{
+		half:        extent / 2,
+		cell:        cell,
+		lift:        extent * 0.01,
+		width:       cell * 0.12,
+		headLength:  cell * 0.3,
+		headWidth:   cell * 0.32,
+		margin:      0.08,
+		bend:        0.2,
+		twistRadius: cell * 0.45,
+		turn:        math.Pi * 1.5,
+	}
+}
+
+// addPermutationArrows 回転記号でピースが移る先を示す矢印と、その場でねじれたコーナー・反転したエッジを示す円弧の矢印を
+// 1 つのメッシュにして、既定のシーンに Arrows ノードとして加える。矢印が無い場合は何もしない
+func addPermutationArrows(doc *gltf.Document, algorithm []string) error {
+	pieces, err := pieceStates(algorithm)
+	if err != nil {
+		return err
+	}
+	extent, err := solvedExtent()
+	if err != nil {
+		return err
+	}
+	g := newArrowGeometry(extent)
+
+	names := make([]string, 0, len(pieces))
+	for name := range pieces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var paths []arrowPath
+	for _, name := range names {
+		p := pieces[name]
+		switch {
+		case p.Kind == centerPiece:
+		case p.Position != p.Home:
+			paths = append(paths, g.cyclePath(p.Home, p.Position))
+		case p.Twist != 0:
+			paths = append(paths, g.twistPath(p.Home, p.Twist))
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var (
+		positions []vector
+		indices   []uint32
+	)
+	for _, path := range paths {
+		positions, indices = g.appendArrow(positions, indices, path)
+	}
+
+	base, ok := baseMaterialIndex(doc)
+	if !ok {
+		return nil
+	}
+	m := copyMaterial(doc.Materials[base])
+	m.Name = "Arrow"
+	m.DoubleSided = true
+	setMaterialColor(m, arrowColor)
+	doc.Materials = append(doc.Materials, m)
+
+	mesh := addMesh(doc, "Arrows", positions, indices, uint32(len(doc.Materials)-1))
+	doc.Nodes = append(doc.Nodes, &gltf.Node{Name: "Arrows", Mesh: gltf.Index(mesh)})
+	scene := uint32(0)
+	if doc.Scene != nil {
+		scene = *doc.Scene
+	}
+	doc.Scenes[scene].Nodes = append(doc.Scenes[scene].Nodes, uint32(len(doc.Nodes)-1))
+	return nil
+}
+
+// stickerPoint 位置 piece のピースの、法線 normal の面のステッカーの少し外側の点を取得する
+func (g arrowGeometry) stickerPoint(piece, normal vector) vector {
+	return add(scaled(piece, g.cell), scaled(normal, g.half+g.lift-g.cell))
+}
+
+// cyclePath from のピースが to に移ることを示す矢印の軸を求める。
+// 2 つの位置が同じ面にある場合はその面の上に、無い場合はキューブの外側を回る曲線にする。
+// 曲線は進む向きの右側に膨らませ、入れ替わる 2 つのピースの矢印が重ならないようにする
+func (g arrowGeometry) cyclePath(from, to vector) arrowPath {
+	for _, f := range degreeFaces {
+		n := faceAxes[f].normal
+		if dot(from, n) != 1 || dot(to, n) != 1 {
+			continue
+		}
+		start, end := g.stickerPoint(from, n), g.stickerPoint(to, n)
+		d := add(end, scaled(start, -1))
+		control := add(scaled(add(start, end), 0.5), scaled(normalize(cross(d, n)), norm(d)*g.bend))
+		return g.bezier(start, control, end, func(vector) vector { return n })
+	}
+
+	start, end := g.stickerPoint(from, outwardFace(from)), g.stickerPoint(to, outwardFace(to))
+	mid := scaled(add(start, end), 0.5)
+	if dot(mid, mid) < 1e-9 {
+		mid = cross(start, vector{0, 1, 0})
+		if dot(mid, mid) < 1e-9 {
+			mid = cross(start, vector{1, 0, 0})
+		}
+	}
+	radius := math.Max(norm(start), norm(end))
+	control := scaled(normalize(mid), radius*1.6)
+	return g.bezier(start, control, end, normalize)
+}
+
+// outwardFace 位置 piece のピースの、U D F B L R の順で最初に外を向いている面の法線を取得する
+func outwardFace(piece vector) vector {
+	for _, f := range degreeFaces {
+		if n := faceAxes[f].normal; dot(piece, n) == 1 {
+			return n
+		}
+	}
+	return vector{0, 1, 0}
+}
+
+// bezier 2 次ベジェ曲線の両端を margin だけ短くした矢印の軸を求める
+func (g arrowGeometry) bezier(start, control, end vector, up func(vector) vector) arrowPath {
+	var path arrowPath
+	for i := 0; i <= arrowSegments; i++ {
+		t := g.margin + (1-2*g.margin)*float64(i)/arrowSegments
+		p := add(add(scaled(start, (1-t)*(1-t)), scaled(control, 2*(1-t)*t)), scaled(end, t*t))
+		path.Points = append(path.Points, p)
+		path.Ups = append(path.Ups, up(p))
+	}
+	return path
+}
+
+// twistPath その場でねじれたピースの外側に、外向きの軸まわりの円弧の矢印の軸を求める。twist が負の場合は逆回り
+func (g arrowGeometry) twistPath(piece vector, twist int) arrowPath {
+	axis := normalize(piece)
+	center := scaled(axis, norm(piece)*g.half+g.lift)
+	u := cross(axis, vector{0, 1, 0})
+	if dot(u, u) < 1e-9 {
+		u = cross(axis, vector{1, 0, 0})
+	}
+	u = normalize(u)
+	v := cross(axis, u)
+
+	var path arrowPath
+	for i := 0; i <= arrowSegments; i++ {
+		angle := float64(twist) * g.turn * float64(i) / arrowSegments
+		p := add(center, add(scaled(u, g.twistRadius*math.Cos(angle)), scaled(v, g.twistRadius*math.Sin(angle))))
+		path.Points = append(path.Points, p)
+		path.Ups = append(path.Ups, axis)
+	}
+	return path
+}
+
+// appendArrow 矢印の軸を帯と矢じりの三角形にして追加する
+func (g arrowGeometry) appendArrow(positions []vector, indices []uint32, path arrowPath) ([]vector, []uint32) {
+	points := path.Points
+	tip := points[len(points)-1]
+	direction := normalize(add(tip, scaled(points[len(points)-2], -1)))
+	base := add(tip, scaled(direction, -g.headLength))
+
+	// 矢じりと重ならないように軸を短くする
+	end := len(points) - 1
+	for end > 1 && norm(add(points[end-1], scaled(tip, -1))) < g.headLength {
+		end--
+	}
+	points = append(points[:end:end], base)
+
+	side := func(i int) vector {
+		a, b := points[i], points[i]
+		if i > 0 {
+			a = points[i-1]
+		}
+		if i+1 < len(points) {
+			b = points[i+1]
+		}
+		return normalize(cross(path.Ups[i], add(b, scaled(a, -1))))
+	}
+
+	first := uint32(len(positions))
+	for i, p := range points {
+		s := scaled(side(i), g.width/2)
+		positions = append(positions, add(p, s), add(p, scaled(s, -1)))
+		if i > 0 {
+			k := first + uint32(i-1)*2
+			indices = append(indices, k, k+1, k+2, k+1, k+3, k+2)
+		}
+	}
+
+	s := scaled(side(len(points)-1), g.headWidth/2)
+	k := uint32(len(positions))
+	positions = append(positions, add(base, s), add(base, scaled(s, -1)), tip)
+	indices = append(indices, k, k+1, k+2)
+
+	return positions, indices
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+func TestAddPermutationArrows(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg string
+		// arrows 矢印の数 (移動したピースとその場でねじれたピースの数)
+		arrows int
+	}{
+		{alg: "", arrows: 0},
+		{alg: "R U R' U' R' F R2 U' R' U' R U R' F'", arrows: 4},
+		{alg: "R' D' R D R' D' R D U R' D' R D R' D' R D R' D' R D R' D' R D U'", arrows: 2},
+		{alg: "R", arrows: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			var doc gltf.Document
+			if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+				t.Fatal(err)
+			}
+			var alg []string
+			if tt.alg != "" {
+				alg = strings.Split(tt.alg, " ")
+			}
+			if err := addPermutationArrows(&doc, alg); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.arrows == 0 {
+				if len(doc.Nodes) != len(gltfDoc.Nodes) {
+					t.Error("arrows must not be added without moved pieces")
+				}
+				return
+			}
+
+			node := doc.Nodes[len(doc.Nodes)-1]
+			if node.Name != "Arrows" || node.Mesh == nil {
+				t.Fatalf("last node must be Arrows: %+v", node)
+			}
+			scene := doc.Scenes[*doc.Scene].Nodes
+			if scene[len(scene)-1] != uint32(len(doc.Nodes)-1) {
+				t.Error("Arrows must be in the default scene")
+			}
+
+			p := doc.Meshes[*node.Mesh].Primitives[0]
+			indices, err := readIndices(&doc, *p.Indices)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// 矢じりは新しい 3 頂点 (k, k+1, k+2) の三角形で、その次の三角形は k+3 から始まる。
+			// 帯の四角形は (k, k+1, k+2), (k+1, k+3, k+2) なので数えない
+			positions, err := readVec3(&doc, p.Attributes["POSITION"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			heads := 0
+			for i := 0; i+2 < len(indices); i += 3 {
+				if indices[i+2] == indices[i]+2 && indices[i+1] == indices[i]+1 && (i+3 == len(indices) || indices[i+3] == indices[i]+3) {
+					heads++
+				}
+			}
+			if heads != tt.arrows {
+				t.Errorf("arrows must be %d, actual: %d (%d vertices)", tt.arrows, heads, len(positions))
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"strconv"
+)
+
+const (
+	imageFormatSVG  = "svg"
+	imageFormatPNG  = "png"
+	imageFormatGIF  = "gif"
+	imageFormatJPEG = "jpg"
+)
+
+type point struct {
+	X, Y float64
+}
+
+// canvas 多角形を描画して画像に変換する 2D の描画先
+type canvas interface {
+	polygon(points []point, fill color.NRGBA)
+	encode() ([]byte, error)
+}
+
+// viewBox 描画に使う座標系の範囲 (最小 X, 最小 Y, 幅, 高さ)
+type viewBox [4]float64
+
+// newCanvas 画像フォーマットに対応する canvas を生成する
+func newCanvas(format string, width, height int, box viewBox, background color.NRGBA) canvas {
+	var c canvas
+	if format == imageFormatSVG {
+		c = newSVGCanvas(width, height, box)
+	} else {
+		c = newRasterCanvas(format, width, height, box)
+	}
+
+	if background.A > 0 {
+		c.polygon([]point{
+			{box[0], box[1]},
+			{box[0] + box[2], box[1]},
+			{box[0] + box[2], box[1] + box[3]},
+			{box[0], box[1] + box[3]},
+		}, background)
+	}
+
+	return c
+}
+
+type svgCanvas struct {
+	buffer *bytes.Buffer
+}
+
+func newSVGCanvas(width, height int, box viewBox) *svgCanvas {
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="%s %s %s %s">`,
+		width, height, formatFloat(box[0]), formatFloat(box[1]), formatFloat(box[2]), formatFloat(box[3]))
+	return &svgCanvas{buffer: buffer}
+}
+
+func (c *svgCanvas) polygon(points []point, fill color.NRGBA) {
+	if fill.A == 0 || len(points) < 3 {
+		return
+	}
+
+	c.buffer.WriteString(`<polygon points="`)
+	for i, p := range points {
+		if i > 0 {
+			c.buffer.WriteByte(' ')
+		}
+		c.buffer.WriteString(formatFloat(p.X) + "," + formatFloat(p.Y))
+	}
+	fmt.Fprintf(c.buffer, `" fill="%s"`, hexColor(fill))
+	if fill.A < 0xff {
+		fmt.Fprintf(c.buffer, ` fill-opacity="%s"`, formatFloat(float64(fill.A)/0xff))
+	}
+	c.buffer.WriteString("/>")
+}
+
+func (c *svgCanvas) encode() ([]byte, error) {
+	c.buffer.WriteString("</svg>")
+	return c.buffer.Bytes(), nil
+}
+
+// rasterCanvas 多角形をピクセルに塗りつぶす canvas。1 ピクセルを 4x4 のサンプルでアンチエイリアスする
+type rasterCanvas struct {
+	format string
+	img    *image.RGBA
+	box    viewBox
+	scaleX float64
+	scaleY float64
+}
+
+const rasterSamples = 4
+
+func newRasterCanvas(format string, width, height int, box viewBox) *rasterCanvas {
+	return &rasterCanvas{
+		format: format,
+		img:    image.NewRGBA(image.Rect(0, 0, width, height)),
+		box:    box,
+		scaleX: float64(width) / box[2],
+		scaleY: float64(height) / box[3],
+	}
+}
+
+func (c *rasterCanvas) polygon(points []point, fill color.NRGBA) {
+	if fill.A == 0 || len(points) < 3 {
+		return
+	}
+
+	pixels := make([]point, len(points))
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i, p := range points {
+		pixels[i] = point{(p.X - c.box[0]) * c.scaleX, (p.Y - c.box[1]) * c.scaleY}
+		minX, maxX = math.Min(minX, pixels[i].X), math.Max(maxX, pixels[i].X)
+		minY, maxY = math.Min(minY, pixels[i].Y), math.Max(maxY, pixels[i].Y)
+	}
+
+	bounds := c.img.Bounds().Intersect(image.Rect(
+		int(math.Floor(minX)), int(math.Floor(minY)),
+		int(math.Ceil(maxX)), int(math.Ceil(maxY)),
+	))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			covered := 0
+			for sy := 0; sy < rasterSamples; sy++ {
+				for sx := 0; sx < rasterSamples; sx++ {
+					if insidePolygon(pixels, point{
+						float64(x) + (float64(sx)+0.5)/rasterSamples,
+						float64(y) + (float64(sy)+0.5)/rasterSamples,
+					}) {
+						covered++
+					}
+				}
+			}
+			if covered > 0 {
+				c.blend(x, y, fill, float64(covered)/(rasterSamples*rasterSamples))
+			}
+		}
+	}
+}
+
+// blend ピクセルに色を coverage の割合で重ねる
+func (c *rasterCanvas) blend(x, y int, fill color.NRGBA, coverage float64) {
+	a := float64(fill.A) / 0xff * coverage
+	i := c.img.PixOffset(x, y)
+	pix := c.img.Pix[i : i+4]
+	for j, v := range [4]uint8{fill.R, fill.G, fill.B, 0xff} {
+		pix[j] = uint8(math.Round(float64(v)*a + float64(pix[j])*(1-a)))
+	}
+}
+
+func (c *rasterCanvas) encode() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+
+	var err error
+	switch c.format {
+	case imageFormatGIF:
+		err = gif.Encode(buffer, c.img, nil)
+	case imageFormatJPEG:
+		err = jpeg.Encode(buffer, c.img, &jpeg.Options{Quality: 90})
+	default:
+		err = png.Encode(buffer, c.img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// insidePolygon 点が多角形の内側にあるかを偶奇規則で判定する
+func insidePolygon(points []point, p point) bool {
+	inside := false
+	for i, j := 0, len(points)-1; i < len(points); j, i = i, i+1 {
+		a, b := points[i], points[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// formatFloat SVG に書き出す数値を小数点以下 4 桁までの表記に変換する
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1e4)/1e4, 'f', -1, 64)
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+)
+
+var (
+	colorTransparent = color.NRGBA{}
+	colorMask        = color.NRGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+)
+
+// colorCodes VisualCube の 1 文字の色コード
+var colorCodes = map[byte]color.NRGBA{
+	'k': {R: 0x00, G: 0x00, B: 0x00, A: 0xff},
+	'd': {R: 0x40, G: 0x40, B: 0x40, A: 0xff},
+	'l': {R: 0x80, G: 0x80, B: 0x80, A: 0xff},
+	's': {R: 0xbb, G: 0xbb, B: 0xbb, A: 0xff},
+	'w': {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	'y': {R: 0xfe, G: 0xfe, B: 0x00, A: 0xff},
+	'r': {R: 0xee, G: 0x00, B: 0x00, A: 0xff},
+	'o': {R: 0xff, G: 0xa1, B: 0x00, A: 0xff},
+	'b': {R: 0x00, G: 0x00, B: 0xf2, A: 0xff},
+	'g': {R: 0x00, G: 0xd8, B: 0x00, A: 0xff},
+	'p': {R: 0xa8, G: 0x3d, B: 0xd9, A: 0xff},
+	'i': {R: 0xf3, G: 0x3d, B: 0x7b, A: 0xff},
+	'n': colorMask,
+	't': colorTransparent,
+}
+
+// colorNames VisualCube の色名と色コードの対応
+var colorNames = map[string]byte{
+	"black":       'k',
+	"dgrey":       'd',
+	"grey":        'l',
+	"silver":      's',
+	"white":       'w',
+	"yellow":      'y',
+	"red":         'r',
+	"orange":      'o',
+	"blue":        'b',
+	"green":       'g',
+	"purple":      'p',
+	"pink":        'i',
+	"transparent": 't',
+}
+
+// parseColor 色コード・色名・16 進数 (#rrggbb または rrggbb) の文字列を色に変換する
+func parseColor(s string) (color.NRGBA, error) {
+	s = strings.ToLower(s)
+	if len(s) == 1 {
+		if c, ok := colorCodes[s[0]]; ok {
+			return c, nil
+		}
+	}
+	if code, ok := colorNames[s]; ok {
+		return colorCodes[code], nil
+	}
+
+	if b, err := hex.DecodeString(strings.TrimPrefix(s, "#")); err == nil && len(b) == 3 {
+		return color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xff}, nil
+	}
+
+	return color.NRGBA{}, fmt.Errorf("unknown color: %s", s)
+}
+
+const (
+	schemeWestern    = "western"
+	schemeJapanese   = "japanese"
+	schemeHalfBright = "half-bright"
+)
+
+// schemePresets parseCubeScheme で指定できる配色の名前
+var schemePresets = []string{schemeWestern, schemeJapanese, schemeHalfBright}
+
+// parseCubeScheme 配色の名前、または parseScheme の形式の文字列を面ごとの色に変換する。
+// western は cube.gltf のまま、japanese は western の黄と青を入れ替えて白の反対を青にした配色、
+// half-bright は western の明るさを半分にした配色
+func parseCubeScheme(s string) ([6]color.NRGBA, error) {
+	return parseSchemeOn(defaultScheme(), s)
+}
+
+// parseSchemeOn western の代わりに base の色を使って、配色の名前を面ごとの色に変換する。
+// 配色の名前でない場合は parseScheme の形式として扱い、base は使わない
+func parseSchemeOn(base [6]color.NRGBA, s string) ([6]color.NRGBA, error) {
+	scheme := base
+	switch strings.ToLower(s) {
+	case schemeWestern:
+	case schemeJapanese:
+		// 白と緑の面はそのままにし、黄と青の面を入れ替える (白の反対が青、緑の反対が黄になる)
+		yellow, ok := westernFace("Yellow")
+		if !ok {
+			return scheme, errors.New("cube.gltf has no yellow stickers")
+		}
+		blue, ok := westernFace("Blue")
+		if !ok {
+			return scheme, errors.New("cube.gltf has no blue stickers")
+		}
+		scheme[yellow], scheme[blue] = scheme[blue], scheme[yellow]
+	case schemeHalfBright:
+		for i, c := range scheme {
+			scheme[i] = color.NRGBA{R: c.R / 2, G: c.G / 2, B: c.B / 2, A: c.A}
+		}
+	default:
+		return parseScheme(s)
+	}
+	return scheme, nil
+}
+
+// westernFace cube.gltf で、マテリアル名が name (White Yellow など) のステッカーがある面を取得する
+func westernFace(name string) (Face, bool) {
+	for _, s := range stickers {
+		if strings.EqualFold(gltfDoc.Materials[s.Material].Name, name) {
+			return Face(s.Home / 9), true
+		}
+	}
+	return FaceU, false
+}
+
+// isSchemePreset 配色の名前かどうかを判定する
+func isSchemePreset(s string) bool {
+	for _, name := range schemePresets {
+		if strings.EqualFold(s, name) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	paletteDeuteranopia = "deuteranopia"
+	paletteProtanopia   = "protanopia"
+	paletteTritanopia   = "tritanopia"
+)
+
+// palettePresets parsePalette で指定できるパレットの名前
+var palettePresets = []string{paletteDeuteranopia, paletteProtanopia, paletteTritanopia}
+
+// palettes 色覚の特性ごとに、見分けにくい組み合わせ (赤とオレンジ、緑と青など) を色相と明るさで区別できるようにした、
+// western の各面 (黄・赤・青・白・オレンジ・緑) の代わりの色。1 型・2 型は Okabe-Ito のカラーユニバーサルデザインの色を使う
+var palettes = map[string][6]color.NRGBA{
+	paletteDeuteranopia: {
+		FaceU: {R: 0xf0, G: 0xe4, B: 0x42, A: 0xff},
+		FaceR: {R: 0xd5, G: 0x5e, B: 0x00, A: 0xff},
+		FaceF: {R: 0x00, G: 0x72, B: 0xb2, A: 0xff},
+		FaceD: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		FaceL: {R: 0xe6, G: 0x9f, B: 0x00, A: 0xff},
+		FaceB: {R: 0x56, G: 0xb4, B: 0xe9, A: 0xff},
+	},
+	paletteProtanopia: {
+		FaceU: {R: 0xf0, G: 0xe4, B: 0x42, A: 0xff},
+		FaceR: {R: 0xcc, G: 0x79, B: 0xa7, A: 0xff},
+		FaceF: {R: 0x00, G: 0x72, B: 0xb2, A: 0xff},
+		FaceD: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		FaceL: {R: 0xe6, G: 0x9f, B: 0x00, A: 0xff},
+		FaceB: {R: 0x00, G: 0x9e, B: 0x73, A: 0xff},
+	},
+	paletteTritanopia: {
+		FaceU: {R: 0xff, G: 0x9d, B: 0xa7, A: 0xff},
+		FaceR: {R: 0xc0, G: 0x00, B: 0x00, A: 0xff},
+		FaceF: {R: 0x00, G: 0x3c, B: 0x8f, A: 0xff},
+		FaceD: {R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		FaceL: {R: 0xe6, G: 0x61, B: 0x00, A: 0xff},
+		FaceB: {R: 0x40, G: 0xe0, B: 0xd0, A: 0xff},
+	},
+}
+
+// parsePalette パレットの名前を、western の並びの面ごとの色に変換する
+func parsePalette(s string) ([6]color.NRGBA, error) {
+	palette, ok := palettes[strings.ToLower(s)]
+	if !ok {
+		return palette, fmt.Errorf("palette must be one of %s", strings.Join(palettePresets, ", "))
+	}
+	return palette, nil
+}
+
+// defaultScheme cube.gltf のステッカーのマテリアルから各面の色を取得する
+func defaultScheme() [6]color.NRGBA {
+	var scheme [6]color.NRGBA
+	for _, s := range stickers {
+		scheme[s.Home/9] = materialColor(gltfDoc, s.Material)
+	}
+	return scheme
+}
+
+// baseMaterialColor 本体 (BaseColor マテリアル) の色を取得する
+func baseMaterialColor(doc *gltf.Document) color.NRGBA {
+	if i, ok := baseMaterialIndex(doc); ok {
+		return materialColor(doc, i)
+	}
+	return color.NRGBA{A: 0xff}
+}
+
+// baseMaterialIndex 本体 (BaseColor マテリアル) のインデックスを取得する
+func baseMaterialIndex(doc *gltf.Document) (uint32, bool) {
+	for i, m := range doc.Materials {
+		if m.Name == "BaseColor" {
+			return uint32(i), true
+		}
+	}
+	return 0, false
+}
+
+// materialColor マテリアルの baseColorFactor (リニア) を sRGB の色に変換する
+func materialColor(doc *gltf.Document, material uint32) color.NRGBA {
+	m := doc.Materials[material]
+	c := *gltf.NewRGBA()
+	if m.PBRMetallicRoughness != nil {
+		c = m.PBRMetallicRoughness.BaseColorFactorOrDefault()
+	}
+
+	return color.NRGBA{
+		R: linearToSRGB(c.R),
+		G: linearToSRGB(c.G),
+		B: linearToSRGB(c.B),
+		A: uint8(math.Round(c.A * 0xff)),
+	}
+}
+
+// setMaterialColor マテリアルの baseColorFactor を sRGB の色 (リニアに変換する) にする。
+// 不透明でない色の場合は alphaMode を BLEND に、不透明な色の場合は OPAQUE にする
+func setMaterialColor(m *gltf.Material, c color.NRGBA) {
+	if m.PBRMetallicRoughness == nil {
+		m.PBRMetallicRoughness = new(gltf.PBRMetallicRoughness)
+	}
+	m.PBRMetallicRoughness.BaseColorFactor = &gltf.RGBA{
+		R: srgbToLinear(c.R),
+		G: srgbToLinear(c.G),
+		B: srgbToLinear(c.B),
+		A: float64(c.A) / 0xff,
+	}
+	m.AlphaMode = gltf.AlphaOpaque
+	if c.A < 0xff {
+		m.AlphaMode = gltf.AlphaBlend
+	}
+}
+
+// srgbToLinear sRGB の 8bit 値をリニアの色成分に変換する
+func srgbToLinear(v uint8) float64 {
+	f := float64(v) / 0xff
+	if f <= 0.04045 {
+		return f / 12.92
+	}
+	return math.Pow((f+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB リニアの色成分を sRGB の 8bit 値に変換する
+func linearToSRGB(v float64) uint8 {
+	if v <= 0.0031308 {
+		v *= 12.92
+	} else {
+		v = 1.055*math.Pow(v, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(math.Max(0, math.Min(1, v)) * 0xff))
+}
+
+// hexColor 色を SVG で使う #rrggbb 形式の文字列に変換する
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -99,21 +99,72 @@ func initCube() error {
 		return err
 	}
 
-	return nil
+	return initStickers(gltfDoc)
 }
 
-func generateCube(algorithm []string) ([]byte, error) {
-	var (
-		doc gltf.Document
-		err error
-		def *Definition
-	)
+func generateCube(algorithm []string, style cubeStyle) ([]byte, error) {
+	var doc gltf.Document
+
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		return nil, err
+	}
+	if err := style.apply(&doc); err != nil {
+		return nil, err
+	}
+
+	nodes, err := applyAlg(doc.Nodes, algorithm)
+	if err != nil {
+		return nil, err
+	}
+	doc.Nodes = nodes
+	if err := explodeNodes(doc.Nodes, style.Explode); err != nil {
+		return nil, err
+	}
+
+	if style.Mirrors > 0 {
+		if err := addMirrors(&doc, style.Mirrors, style.Hide); err != nil {
+			return nil, err
+		}
+	}
+	if style.Arrows {
+		if err := addPermutationArrows(&doc, algorithm); err != nil {
+			return nil, err
+		}
+	}
+	style.hide(&doc)
+	lighting, err := style.addLighting(&doc, false)
+	if err != nil {
+		return nil, err
+	}
+	if style.View != nil {
+		if err := addCameraView(&doc, style.View, lighting); err != nil {
+			return nil, err
+		}
+	}
+	if style.Describe != "" {
+		if err := describeAsset(&doc, algorithm, style); err != nil {
+			return nil, err
+		}
+	}
 
-	if err = deepcopy.Copy(&doc, gltfDoc); err != nil {
+	return encodeGltf(&doc)
+}
+
+// encodeGltf glTF を JSON 形式で出力する
+func encodeGltf(doc *gltf.Document) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	e := gltf.NewEncoder(buffer)
+	e.AsBinary = false
+	if err := e.Encode(doc); err != nil {
 		return nil, err
 	}
 
-	def, err = nodeToDefinition(doc.Nodes)
+	return buffer.Bytes(), nil
+}
+
+// applyAlg ノードに回転記号を適用し、回転後のノードを取得する。引数のノードは変更しない
+func applyAlg(nodes []*gltf.Node, algorithm []string) ([]*gltf.Node, error) {
+	def, err := nodeToDefinition(nodes)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +178,12 @@ func generateCube(algorithm []string) ([]byte, error) {
 		rotate(def, d)
 	}
 
-	doc.Nodes = []*gltf.Node{
+	return def.nodes(), nil
+}
+
+// nodes Definition のノードを applyAlg の結果と同じ順で取得する
+func (def *Definition) nodes() []*gltf.Node {
+	return []*gltf.Node{
 		&def.UBL, &def.UBM, &def.UBR,
 		&def.UML, &def.UMM, &def.UMR,
 		&def.UFL, &def.UFM, &def.UFR,
@@ -138,15 +194,6 @@ func generateCube(algorithm []string) ([]byte, error) {
 		&def.DML, &def.DMM, &def.DMR,
 		&def.DFL, &def.DFM, &def.DFR,
 	}
-
-	buffer := new(bytes.Buffer)
-	e := gltf.NewEncoder(buffer)
-	e.AsBinary = false
-	if err := e.Encode(&doc); err != nil {
-		return nil, err
-	}
-
-	return buffer.Bytes(), nil
 }
 
 // nodeToDefinition
@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	langEnglish  = "en"
+	langJapanese = "ja"
+)
+
+// describeLangs 説明文の言語
+var describeLangs = []string{langEnglish, langJapanese}
+
+// describeText 言語ごとの説明文の部品
+type describeText struct {
+	Solved       string
+	SolvedPieces string // 揃っているピースの数と全体の数
+	Unsolved     string // 揃っていない位置の一覧
+	Cross        string
+	F2L          string
+	PLL          string // PLL の名前と、側面の上段の特徴
+	PLLPlain     string // 特徴が無い場合の PLL の名前
+	AUF          string // U 面を回すだけで揃う場合の回転記号
+	OLL          string // 向きが揃っているエッジの形と、向きが揃っているコーナーの数
+	Face         string // 面の名前と行ごとの色
+	Row          string // 行の番号と色
+	Separator    string
+	RowSeparator string
+	Headlights   string
+	Bar          string
+	EdgeShapes   map[int]string // 向きが揃っているエッジの形
+	FaceNames    [6]string
+	Sides        map[Face]string
+	Masked       string            // マスクしたステッカー
+	Colors       map[string]string // colorNames の色名と色の名前
+}
+
+var describeTexts = map[string]describeText{
+	langEnglish: {
+		Solved:       "The cube is solved.",
+		SolvedPieces: "%d of %d pieces are solved.",
+		Unsolved:     "Unsolved positions: %s.",
+		Cross:        "The cross on the Down face is solved.",
+		F2L:          "The first two layers are solved.",
+		PLL:          "Last layer: %s, with %s.",
+		PLLPlain:     "Last layer: %s.",
+		AUF:          "Last layer: solved after %s.",
+		OLL:          "Last layer: not oriented; the edges form %s, and %d of 4 corners are oriented.",
+		Face:         "%s face: %s.",
+		Row:          "row %d %s",
+		Separator:    ", ",
+		RowSeparator: "; ",
+		Headlights:   "headlights on the %s",
+		Bar:          "a bar on the %s",
+		EdgeShapes:   map[int]string{0: "a dot", 1: "an L shape", 2: "a line", 4: "a cross"},
+		FaceNames:    [6]string{"Up", "Right", "Front", "Down", "Left", "Back"},
+		Sides:        map[Face]string{FaceF: "front", FaceR: "right", FaceB: "back", FaceL: "left"},
+		Masked:       "masked",
+		Colors: map[string]string{
+			"white": "white", "yellow": "yellow", "red": "red", "orange": "orange", "blue": "blue", "green": "green",
+			"black": "black", "dgrey": "dark grey", "grey": "grey", "silver": "silver", "purple": "purple", "pink": "pink",
+			"transparent": "transparent",
+		},
+	},
+	langJapanese: {
+		Solved:       "キューブは完成しています。",
+		SolvedPieces: "%[2]d 個中 %[1]d 個のピースが揃っています。",
+		Unsolved:     "揃っていない位置: %s。",
+		Cross:        "下面のクロスが揃っています。",
+		F2L:          "1・2 段目が揃っています。",
+		PLL:          "最終段は %s で、%sがあります。",
+		PLLPlain:     "最終段は %s です。",
+		AUF:          "最終段は %s で揃います。",
+		OLL:          "最終段の向きが揃っていません (エッジ: %s、コーナー: 4 個中 %d 個)。",
+		Face:         "%s: %s。",
+		Row:          "%d 行目 %s",
+		Separator:    "、",
+		RowSeparator: "; ",
+		Headlights:   "%sにヘッドライト",
+		Bar:          "%sにバー",
+		EdgeShapes:   map[int]string{0: "点", 1: "L 字", 2: "棒", 4: "十字"},
+		FaceNames:    [6]string{"上面", "右面", "前面", "下面", "左面", "後面"},
+		Sides:        map[Face]string{FaceF: "前", FaceR: "右", FaceB: "後ろ", FaceL: "左"},
+		Masked:       "マスク",
+		Colors: map[string]string{
+			"white": "白", "yellow": "黄", "red": "赤", "orange": "オレンジ", "blue": "青", "green": "緑",
+			"black": "黒", "dgrey": "濃い灰", "grey": "灰", "silver": "銀", "purple": "紫", "pink": "ピンク",
+			"transparent": "透明",
+		},
+	},
+}
+
+// pllCase PLL の名前と、その PLL を揃える面の回転だけの手順
+type pllCase struct {
+	Name string
+	Alg  string
+}
+
+var pllCases = []pllCase{
+	{"Aa-perm", "R' F R' B2 R F' R' B2 R2"},
+	{"Ab-perm", "R2 B2 R F R' B2 R F' R"},
+	{"E-perm", "R B' R' F R B R' F' R B R' F R B' R' F'"},
+	{"F-perm", "R' U' F' R U R' U' R' F R2 U' R' U' R U R' U R"},
+	{"Ga-perm", "R2 U R' U R' U' R U' R2 D U' R' U R D'"},
+	{"Gb-perm", "R' U' R U D' R2 U R' U R U' R U' R2 D"},
+	{"Gc-perm", "R2 U' R U' R U R' U R2 D' U R U' R' D"},
+	{"Gd-perm", "R U R' U' D R2 U' R U' R' U R' U R2 D'"},
+	{"H-perm", "R2 U2 R U2 R2 U2 R2 U2 R U2 R2"},
+	{"Ja-perm", "R' U L' U2 R U' R' U2 R L"},
+	{"Jb-perm", "R U R' F' R U R' U' R' F R2 U' R'"},
+	{"Na-perm", "R U R' U R U R' F' R U R' U' R' F R2 U' R' U2 R U' R'"},
+	{"Nb-perm", "R' U R U' R' F' U' F R U R' F R' F' R U' R"},
+	{"Ra-perm", "R U' R' U' R U R D R' U' R D' R' U2 R'"},
+	{"Rb-perm", "R2 F R U R U' R' F' R U2 R' U2 R"},
+	{"T-perm", "R U R' U' R' F R2 U' R' U' R U R' F'"},
+	{"Ua-perm", "R U' R U R U R U' R' U' R2"},
+	{"Ub-perm", "R2 U R U R' U' R' U' R' U R'"},
+	{"V-perm", "R' U R' U' B' R' B2 U' B' U B' R B R"},
+	{"Y-perm", "F R U' R' U' R U R' F' R U R' U' R' F R F'"},
+	{"Z-perm", "R U R' U R' U' R' U R U' R' U' R2 U R"},
+}
+
+var (
+	pllOnce   sync.Once
+	pllStates map[string]string
+	pllErr    error
+)
+
+// aufMoves U 面を k 回 (時計回りに 90 度ずつ) 回す回転記号
+var aufMoves = [4][]string{nil, {"U"}, {"U2"}, {"U'"}}
+
+// recognizePLL 1・2 段目と最終段の向きが揃った状態から、最終段のピースの並びが当てはまる PLL の名前を取得する。
+// 前後に U 面を回した状態も同じ PLL とする。当てはまらない場合は空文字列
+func recognizePLL(pieces map[string]pieceState) (string, error) {
+	pllOnce.Do(func() {
+		pllStates = make(map[string]string)
+		for _, c := range pllCases {
+			// PLL の手順で揃う状態は、完成状態に逆手順を適用した状態
+			inverse := invertAlg(strings.Fields(c.Alg))
+			for _, before := range aufMoves {
+				for _, after := range aufMoves {
+					alg := append(append(append([]string{}, before...), inverse...), after...)
+					p, err := pieceStates(alg)
+					if err != nil {
+						pllErr = err
+						return
+					}
+					if key := lastLayerKey(p); pllStates[key] == "" {
+						pllStates[key] = c.Name
+					}
+				}
+			}
+		}
+	})
+	if pllErr != nil {
+		return "", pllErr
+	}
+	return pllStates[lastLayerKey(pieces)], nil
+}
+
+// lastLayerKey U 面の層の位置ごとにあるピースを、位置の名前順に並べた文字列にする
+func lastLayerKey(pieces map[string]pieceState) string {
+	var entries []string
+	for _, p := range pieces {
+		if p.Position[1] == 1 && p.Kind != centerPiece {
+			entries = append(entries, pieceName(p.Position)+":"+pieceName(p.Home))
+		}
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, " ")
+}
+
+// describeCube 回転記号を適用したキューブの状態を、スクリーンリーダー向けの文章にする。
+// 揃っているピースの数、最終段の状態 (1・2 段目が揃っている場合)、面ごとの行ごとの色を順に説明する。
+// 色の名前は style の配色・マスク・ステッカーごとの色に合わせる
+func describeCube(algorithm []string, lang string, style cubeStyle) (string, error) {
+	text, ok := describeTexts[lang]
+	if !ok {
+		return "", fmt.Errorf("lang must be one of %s", strings.Join(describeLangs, ", "))
+	}
+
+	pieces, err := pieceStates(algorithm)
+	if err != nil {
+		return "", err
+	}
+	nodes, err := applyAlg(gltfDoc.Nodes, algorithm)
+	if err != nil {
+		return "", err
+	}
+	state, err := stateOf(nodes)
+	if err != nil {
+		return "", err
+	}
+	// 最終段の判定は cube.gltf の色で行い、面ごとの色は style に合わせた名前で説明する
+	colors := make([]string, faceletCount)
+	names := make([]string, faceletCount)
+	styleNames := style.stickerColorNames()
+	for f, i := range state {
+		colors[f] = gltfDoc.Materials[stickers[i].Material].Name
+		names[f] = text.Masked
+		if name := styleNames[stickers[i].Home]; name != "" {
+			names[f] = text.Colors[name]
+		}
+	}
+
+	var (
+		sentences []string
+		unsolved  []string
+		total     int
+		cross     = true
+		f2l       = true
+	)
+	for _, p := range pieces {
+		if p.Kind == centerPiece {
+			continue
+		}
+		total++
+		if !p.Unsolved {
+			continue
+		}
+		unsolved = append(unsolved, pieceName(p.Position))
+		if p.Home[1] == -1 && p.Kind == edgePiece {
+			cross = false
+		}
+		if p.Home[1] != 1 {
+			f2l = false
+		}
+	}
+	sort.Strings(unsolved)
+
+	if len(unsolved) == 0 {
+		sentences = append(sentences, text.Solved)
+	} else {
+		sentences = append(sentences,
+			fmt.Sprintf(text.SolvedPieces, total-len(unsolved), total),
+			fmt.Sprintf(text.Unsolved, strings.Join(unsolved, ", ")),
+		)
+		switch {
+		case f2l:
+			sentences = append(sentences, text.F2L)
+			s, err := describeLastLayer(text, pieces, colors)
+			if err != nil {
+				return "", err
+			}
+			sentences = append(sentences, s)
+		case cross:
+			sentences = append(sentences, text.Cross)
+		}
+	}
+
+	for f, name := range text.FaceNames {
+		rows := make([]string, 3)
+		for r := range rows {
+			rows[r] = fmt.Sprintf(text.Row, r+1, strings.Join(names[f*9+r*3:f*9+r*3+3], text.Separator))
+		}
+		sentences = append(sentences, fmt.Sprintf(text.Face, name, strings.Join(rows, text.RowSeparator)))
+	}
+
+	separator := " "
+	if lang == langJapanese {
+		separator = ""
+	}
+	return strings.Join(sentences, separator), nil
+}
+
+// describeLastLayer 1・2 段目が揃った状態の最終段を説明する。
+// 向きが揃っていればヘッドライト (側面の上段の両端が同じ色) とバー (上段がすべて同じ色) の位置と PLL の名前、
+// 揃っていなければエッジの形と向きが揃っているコーナーの数を説明する
+func describeLastLayer(text describeText, pieces map[string]pieceState, colors []string) (string, error) {
+	up := colors[int(FaceU)*9+4]
+	edges, corners := 0, 0
+	for _, i := range labelEdges {
+		if colors[int(FaceU)*9+i] == up {
+			edges++
+		}
+	}
+	for _, i := range labelCorners {
+		if colors[int(FaceU)*9+i] == up {
+			corners++
+		}
+	}
+
+	if edges < 4 || corners < 4 {
+		// 向きが揃っている 2 個のエッジが隣り合う場合は L 字、向かい合う場合は棒
+		shape := edges
+		if edges == 2 && colors[int(FaceU)*9+1] != colors[int(FaceU)*9+7] && colors[int(FaceU)*9+3] != colors[int(FaceU)*9+5] {
+			shape = 1
+		}
+		return fmt.Sprintf(text.OLL, text.EdgeShapes[shape], corners), nil
+	}
+
+	for k, moves := range aufMoves[1:] {
+		p, err := pieceStates(moves)
+		if err != nil {
+			return "", err
+		}
+		if lastLayerKey(p) == lastLayerKey(pieces) {
+			// 揃えるには逆向きに回す
+			return fmt.Sprintf(text.AUF, aufMoves[3-k][0]), nil
+		}
+	}
+
+	name, err := recognizePLL(pieces)
+	if err != nil {
+		return "", err
+	}
+	var features []string
+	for _, side := range []Face{FaceF, FaceR, FaceB, FaceL} {
+		row := colors[int(side)*9 : int(side)*9+3]
+		switch {
+		case row[0] == row[1] && row[1] == row[2]:
+			features = append(features, fmt.Sprintf(text.Bar, text.Sides[side]))
+		case row[0] == row[2]:
+			features = append(features, fmt.Sprintf(text.Headlights, text.Sides[side]))
+		}
+	}
+	if len(features) == 0 {
+		return fmt.Sprintf(text.PLLPlain, name), nil
+	}
+	return fmt.Sprintf(text.PLL, name, strings.Join(features, text.Separator)), nil
+}
+
+// describeAsset glTF の asset.extras.description に、style.Describe の言語の状態の説明を入れる
+func describeAsset(doc *gltf.Document, algorithm []string, style cubeStyle) error {
+	d, err := describeCube(algorithm, style.Describe, style)
+	if err != nil {
+		return err
+	}
+	doc.Asset.Extras = map[string]interface{}{"description": map[string]string{style.Describe: d}}
+	return nil
+}
+
+// stickerColorNames 完成状態の位置ごとに、style を適用したステッカーの色の名前 (colorNames の色名) を取得する。
+// マスクしたステッカーは空文字列にする
+func (s cubeStyle) stickerColorNames() [faceletCount]string {
+	var names [faceletCount]string
+	for _, sticker := range stickers {
+		switch {
+		case sticker.Home < len(s.Colors):
+			names[sticker.Home] = nearestColorName(s.Colors[sticker.Home])
+		case s.Mask != nil && s.Mask[sticker.Home]:
+		case s.Scheme != nil:
+			names[sticker.Home] = nearestColorName(s.Scheme[sticker.Home/9])
+		default:
+			names[sticker.Home] = strings.ToLower(gltfDoc.Materials[sticker.Material].Name)
+		}
+	}
+	return names
+}
+
+// nearestColorName 色の名前 (colorNames の色名) を取得する。
+// cube.gltf の各面の色とパレットの色はその面の western の色の名前にし、それ以外は最も近い色の名前にする
+func nearestColorName(c color.NRGBA) string {
+	if c.A == 0 {
+		return "transparent"
+	}
+
+	var faceNames [6]string
+	for _, s := range stickers {
+		faceNames[s.Home/9] = strings.ToLower(gltfDoc.Materials[s.Material].Name)
+	}
+	schemes := [][6]color.NRGBA{defaultScheme()}
+	for _, name := range palettePresets {
+		schemes = append(schemes, palettes[name])
+	}
+	for _, scheme := range schemes {
+		for f, sc := range scheme {
+			if sc == c {
+				return faceNames[f]
+			}
+		}
+	}
+
+	nearest, distance := "", math.Inf(1)
+	for name, code := range colorNames {
+		n := colorCodes[code]
+		if n.A == 0 {
+			continue
+		}
+		dr, dg, db := float64(c.R)-float64(n.R), float64(c.G)-float64(n.G), float64(c.B)-float64(n.B)
+		// 同じ距離の場合も結果が変わらないように、名前の順で選ぶ
+		if d := dr*dr + dg*dg + db*db; d < distance || (d == distance && name < nearest) {
+			nearest, distance = name, d
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+)
+
+func TestRecognizePLL(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range pllCases {
+		alg := append(invertAlg(strings.Fields(c.Alg)), "U2")
+		pieces, err := pieceStates(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name, err := recognizePLL(pieces)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != c.Name {
+			t.Errorf("%s: recognized as %q", c.Name, name)
+		}
+	}
+
+	// 向きが揃った最終段の並び 288 通りのうち、U 面を回すだけで揃う 4 通り以外がすべて PLL に当てはまる
+	if len(pllStates) != 288-4 {
+		t.Errorf("PLL must cover %d states, actual: %d", 288-4, len(pllStates))
+	}
+}
+
+func TestDescribeCube(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg, lang string
+		contains  []string
+	}{
+		{alg: "", lang: langEnglish, contains: []string{"The cube is solved.", "Up face: row 1 yellow, yellow, yellow;"}},
+		{alg: "R", lang: langEnglish, contains: []string{"12 of 20 pieces are solved.", "Unsolved positions: BR, DBR, DFR, DR, FR, UBR, UFR, UR."}},
+		{alg: "R U R' U'", lang: langEnglish, contains: []string{"The cross on the Down face is solved."}},
+		{alg: "U", lang: langEnglish, contains: []string{"Last layer: solved after U'."}},
+		{alg: "F R U R' U' F'", lang: langEnglish, contains: []string{"the edges form an L shape, and 2 of 4 corners are oriented."}},
+		{
+			alg:      "R U R' U' R' F R2 U' R' U' R U R' F'",
+			lang:     langEnglish,
+			contains: []string{"The first two layers are solved.", "Last layer: T-perm, with headlights on the left."},
+		},
+		{
+			alg:      "R U R' U' R' F R2 U' R' U' R U R' F'",
+			lang:     langJapanese,
+			contains: []string{"20 個中 16 個のピースが揃っています。", "最終段は T-perm で、左にヘッドライトがあります。", "上面: 1 行目 黄、黄、黄;"},
+		},
+	}
+	for _, tt := range tests {
+		s, err := describeCube(strings.Fields(tt.alg), tt.lang, cubeStyle{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range tt.contains {
+			if !strings.Contains(s, c) {
+				t.Errorf("%s (%s): %q must contain %q", tt.alg, tt.lang, s, c)
+			}
+		}
+	}
+
+	if _, err := describeCube(nil, "fr", cubeStyle{}); err == nil {
+		t.Error("unknown lang must be an error")
+	}
+}
+
+func TestDescribeCubeStyle(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	japanese, err := parseCubeScheme(schemeJapanese)
+	if err != nil {
+		t.Fatal(err)
+	}
+	palette, err := parsePalette(paletteDeuteranopia)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask, err := stageMask("cross")
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors, err := parseFaceletColors("p")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		style    cubeStyle
+		lang     string
+		contains []string
+	}{
+		{
+			name:     "sch=japanese",
+			style:    cubeStyle{Scheme: &japanese},
+			lang:     langEnglish,
+			contains: []string{"Up face: row 1 blue, blue, blue;", "Front face: row 1 yellow, yellow, yellow;"},
+		},
+		{
+			name:     "sch=japanese",
+			style:    cubeStyle{Scheme: &japanese},
+			lang:     langJapanese,
+			contains: []string{"上面: 1 行目 青、青、青;"},
+		},
+		{
+			name:     "palette=deuteranopia",
+			style:    cubeStyle{Scheme: &palette},
+			lang:     langEnglish,
+			contains: []string{"Up face: row 1 yellow, yellow, yellow;"},
+		},
+		{
+			name:     "stage=cross",
+			style:    cubeStyle{Mask: &mask},
+			lang:     langEnglish,
+			contains: []string{"Up face: row 1 masked, masked, masked;", "Down face: row 1 masked, white, masked;"},
+		},
+		{
+			name:     "fc=p",
+			style:    cubeStyle{Colors: colors},
+			lang:     langEnglish,
+			contains: []string{"Up face: row 1 purple, yellow, yellow;"},
+		},
+	}
+	for _, tt := range tests {
+		s, err := describeCube(nil, tt.lang, tt.style)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range tt.contains {
+			if !strings.Contains(s, c) {
+				t.Errorf("%s (%s): %q must contain %q", tt.name, tt.lang, s, c)
+			}
+		}
+	}
+
+	// glTF の asset.extras.description も配色に合わせる
+	data, err := generateCube(nil, cubeStyle{Scheme: &japanese, Describe: langEnglish})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc gltf.Document
+	if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	description := fmt.Sprint(doc.Asset.Extras)
+	if !strings.Contains(description, "Up face: row 1 blue, blue, blue;") {
+		t.Errorf("description must follow sch=japanese, actual: %s", description)
+	}
+}
+
+func TestDescribeAsset(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		valid bool
+		// langs asset.extras.description に入る言語
+		langs []string
+	}{
+		{query: "alg=R", valid: true},
+		{query: "alg=R&describe=ja", valid: true, langs: []string{langJapanese}},
+		{query: "alg=R&describe=en&animate=1", valid: true, langs: []string{langEnglish}},
+		{query: "alg=R&describe=fr"},
+		{query: "alg=R&describe=en&format=svg"},
+	}
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := bindGetCubeHandlerRequest(values)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: valid must be %v, actual: %v", tt.query, tt.valid, err)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+
+		var data []byte
+		if req.Animation != nil {
+			data, err = generateAnimatedCube(req.Algorithm, *req.Animation, req.Style)
+		} else {
+			data, err = generateCube(req.Algorithm, req.Style)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var doc gltf.Document
+		if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+			t.Fatal(err)
+		}
+
+		// 説明文は describe を指定した場合だけ、その言語のものを入れる
+		var langs []string
+		if extras, ok := doc.Asset.Extras.(map[string]interface{}); ok {
+			descriptions, _ := extras["description"].(map[string]interface{})
+			for lang := range descriptions {
+				langs = append(langs, lang)
+			}
+		}
+		if !reflect.DeepEqual(langs, tt.langs) {
+			t.Errorf("%s: descriptions must be %v, actual: %v", tt.query, tt.langs, langs)
+		}
+	}
+}
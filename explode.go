@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/qmuntal/gltf"
+)
+
+// maxExplode explode の上限 (ピース 1 個の幅に対する割合)
+const maxExplode = 3.0
+
+// exploder ピースを現在の位置の向きに外側へ離す translation を求める
+type exploder struct {
+	distance float64               // 各成分が ±1 のピースを離す距離
+	initial  map[string][4]float64 // cube.gltf のノードの rotation
+}
+
+// newExploder ピース 1 個の幅に対して explode の割合だけ離す exploder を作る
+func newExploder(explode float64) (*exploder, error) {
+	extent, err := solvedExtent()
+	if err != nil {
+		return nil, err
+	}
+
+	e := &exploder{distance: explode * extent / 3, initial: make(map[string][4]float64, len(gltfDoc.Nodes))}
+	for _, node := range gltfDoc.Nodes {
+		e.initial[node.Name] = node.RotationOrDefault()
+	}
+	return e, nil
+}
+
+// offset 完成状態から rotation まで回転したノードの translation を求める
+func (e *exploder) offset(name string, rotation [4]float64) ([3]float64, error) {
+	home, err := nodeHome(name)
+	if err != nil {
+		return [3]float64{}, err
+	}
+	initial, ok := e.initial[name]
+	if !ok {
+		return [3]float64{}, fmt.Errorf("node not found: %s", name)
+	}
+
+	// 完成状態の向きに戻してから現在の向きに回すと、ピースの現在の位置になる
+	inverse := [4]float64{-initial[0], -initial[1], -initial[2], initial[3]}
+	return scaled(rotateVector(rotation, rotateVector(inverse, home)), e.distance), nil
+}
+
+// apply ノードの rotation に合わせて translation を設定する
+func (e *exploder) apply(nodes []*gltf.Node) error {
+	for _, node := range nodes {
+		offset, err := e.offset(node.Name, node.RotationOrDefault())
+		if err != nil {
+			return err
+		}
+		node.Translation = offset
+	}
+	return nil
+}
+
+// explodeNodes explode が 0 より大きい場合に、ノードをピース 1 個の幅に対する explode の割合だけ外側へ離す
+func explodeNodes(nodes []*gltf.Node, explode float64) error {
+	if explode <= 0 {
+		return nil
+	}
+	e, err := newExploder(explode)
+	if err != nil {
+		return err
+	}
+	return e.apply(nodes)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExplodeNodes(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	extent, err := solvedExtent()
+	if err != nil {
+		t.Fatal(err)
+	}
+	distance := 0.5 * extent / 3
+
+	nodes, err := applyAlg(gltfDoc.Nodes, []string{"R"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := explodeNodes(nodes, 0.5); err != nil {
+		t.Fatal(err)
+	}
+
+	// R で DFR は UFR へ、UFR は UBR へ移り、センターは動かない
+	want := map[string]vector{
+		"DFR": {distance, distance, distance},
+		"UFR": {distance, distance, -distance},
+		"MMR": {distance, 0, 0},
+		"UMM": {0, distance, 0},
+	}
+	for _, node := range nodes {
+		w, ok := want[node.Name]
+		if !ok {
+			continue
+		}
+		for i := range w {
+			if math.Abs(node.Translation[i]-w[i]) > 1e-9 {
+				t.Errorf("translation of %s must be %v, actual: %v", node.Name, w, node.Translation)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"math"
+	"sort"
+)
+
+// gifEncoder フレームごとに色を減らして GIF にまとめる
+type gifEncoder struct {
+	gif         gif.GIF
+	transparent bool
+}
+
+func newGIFEncoder(loop int, transparent bool) *gifEncoder {
+	e := &gifEncoder{transparent: transparent}
+	// image/gif の LoopCount は 0 で無限、-1 で 1 回、n で n + 1 回の再生
+	switch {
+	case loop == 1:
+		e.gif.LoopCount = -1
+	case loop > 1:
+		e.gif.LoopCount = loop - 1
+	}
+	return e
+}
+
+func (e *gifEncoder) add(img *image.NRGBA, delay float64) error {
+	e.gif.Image = append(e.gif.Image, quantize(img, e.transparent))
+	e.gif.Delay = append(e.gif.Delay, int(math.Round(delay*100)))
+	if e.transparent {
+		e.gif.Disposal = append(e.gif.Disposal, gif.DisposalBackground)
+	} else {
+		e.gif.Disposal = append(e.gif.Disposal, gif.DisposalNone)
+	}
+	return nil
+}
+
+func (e *gifEncoder) encode() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	if err := gif.EncodeAll(buffer, &e.gif); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// quantize 画像を 256 色以下のパレット画像にする。
+// 各チャンネルを 5bit にまとめた色の出現回数が多い順にパレットを作り、パレットに無い色は最も近い色にする。
+// transparent の場合はパレットの先頭を透明にし、半分以上透明なピクセルに使う
+func quantize(img *image.NRGBA, transparent bool) *image.Paletted {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	key := func(c color.NRGBA) int { return int(c.R>>3)<<10 | int(c.G>>3)<<5 | int(c.B>>3) }
+
+	buckets := make(map[int]*bucket)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if transparent && c.A < 0x80 {
+				continue
+			}
+			b, ok := buckets[key(c)]
+			if !ok {
+				b = new(bucket)
+				buckets[key(c)] = b
+			}
+			b.count++
+			b.r += int(c.R)
+			b.g += int(c.G)
+			b.b += int(c.B)
+		}
+	}
+
+	keys := make([]int, 0, len(buckets))
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if buckets[keys[i]].count != buckets[keys[j]].count {
+			return buckets[keys[i]].count > buckets[keys[j]].count
+		}
+		return keys[i] < keys[j]
+	})
+
+	var palette color.Palette
+	if transparent {
+		palette = append(palette, color.NRGBA{})
+	}
+	first := len(palette)
+	indices := make(map[int]uint8, len(keys))
+	for _, k := range keys {
+		if len(palette) == 256 {
+			break
+		}
+		b := buckets[k]
+		indices[k] = uint8(len(palette))
+		palette = append(palette, color.NRGBA{
+			R: uint8(b.r / b.count), G: uint8(b.g / b.count), B: uint8(b.b / b.count), A: 0xff,
+		})
+	}
+	if len(palette) == 0 {
+		palette = append(palette, color.NRGBA{A: 0xff})
+	}
+
+	nearest := func(c color.NRGBA) uint8 {
+		best, distance := first, math.MaxInt32
+		for i := first; i < len(palette); i++ {
+			p := palette[i].(color.NRGBA)
+			dr, dg, db := int(p.R)-int(c.R), int(p.G)-int(c.G), int(p.B)-int(c.B)
+			if d := dr*dr + dg*dg + db*db; d < distance {
+				best, distance = i, d
+			}
+		}
+		return uint8(best)
+	}
+
+	paletted := image.NewPaletted(bounds, palette)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.NRGBAAt(x, y)
+			if transparent && c.A < 0x80 {
+				paletted.SetColorIndex(x, y, 0)
+				continue
+			}
+			i, ok := indices[key(c)]
+			if !ok {
+				i = nearest(c)
+				indices[key(c)] = i
+			}
+			paletted.SetColorIndex(x, y, i)
+		}
+	}
+
+	return paletted
+}
+
+// apngEncoder フレームを PNG にエンコードして APNG にまとめる。
+// すべてのフレームが同じ IHDR になるように、不透明なフレームも常に 8 bit の RGBA で書き込む
+type apngEncoder struct {
+	loop        int
+	transparent bool
+	header      []byte // 最初のフレームの IHDR チャンクのデータ
+	frames      [][]byte
+	delays      []float64
+}
+
+func newAPNGEncoder(loop int, transparent bool) *apngEncoder {
+	return &apngEncoder{loop: loop, transparent: transparent}
+}
+
+func (e *apngEncoder) add(img *image.NRGBA, delay float64) error {
+	bounds := img.Bounds()
+	if e.header == nil {
+		e.header = make([]byte, 13)
+		binary.BigEndian.PutUint32(e.header, uint32(bounds.Dx()))
+		binary.BigEndian.PutUint32(e.header[4:], uint32(bounds.Dy()))
+		e.header[8] = 8 // ビット深度
+		e.header[9] = 6 // カラータイプ: RGBA
+	}
+
+	// 各行の先頭にフィルターの種類を置き、上の行との差 (Up) を書き込む
+	buffer := new(bytes.Buffer)
+	w := zlib.NewWriter(buffer)
+	row, previous := make([]byte, 1+bounds.Dx()*4), make([]byte, bounds.Dx()*4)
+	row[0] = 2
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		pix := img.Pix[img.PixOffset(bounds.Min.X, y):][:bounds.Dx()*4]
+		for i, v := range pix {
+			row[1+i] = v - previous[i]
+		}
+		if _, err := w.Write(row); err != nil {
+			return err
+		}
+		copy(previous, pix)
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	e.frames = append(e.frames, buffer.Bytes())
+	e.delays = append(e.delays, delay)
+	return nil
+}
+
+func (e *apngEncoder) encode() ([]byte, error) {
+	if len(e.frames) == 0 {
+		return nil, errors.New("apng has no frames")
+	}
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("\x89PNG\r\n\x1a\n")
+	writePNGChunk(buffer, "IHDR", e.header)
+
+	actl := make([]byte, 8)
+	binary.BigEndian.PutUint32(actl, uint32(len(e.frames)))
+	binary.BigEndian.PutUint32(actl[4:], uint32(e.loop))
+	writePNGChunk(buffer, "acTL", actl)
+
+	// dispose_op: 0 はそのまま、1 は次のフレームの前に透明にする
+	dispose := byte(0)
+	if e.transparent {
+		dispose = 1
+	}
+
+	sequence := uint32(0)
+	for i, data := range e.frames {
+		fctl := make([]byte, 26)
+		binary.BigEndian.PutUint32(fctl, sequence)
+		copy(fctl[4:12], e.header[0:8]) // 幅と高さ
+		binary.BigEndian.PutUint16(fctl[20:], uint16(math.Min(math.Round(e.delays[i]*1000), 0xffff)))
+		binary.BigEndian.PutUint16(fctl[22:], 1000)
+		fctl[24] = dispose
+		writePNGChunk(buffer, "fcTL", fctl)
+		sequence++
+
+		if i == 0 {
+			writePNGChunk(buffer, "IDAT", data)
+			continue
+		}
+		fdat := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(fdat, sequence)
+		copy(fdat[4:], data)
+		writePNGChunk(buffer, "fdAT", fdat)
+		sequence++
+	}
+
+	writePNGChunk(buffer, "IEND", nil)
+	return buffer.Bytes(), nil
+}
+
+// writePNGChunk PNG のチャンクを長さと CRC を付けて書き込む
+func writePNGChunk(buffer *bytes.Buffer, name string, data []byte) {
+	_ = binary.Write(buffer, binary.BigEndian, uint32(len(data)))
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write([]byte(name))
+	_, _ = crc.Write(data)
+	buffer.WriteString(name)
+	buffer.Write(data)
+	_ = binary.Write(buffer, binary.BigEndian, crc.Sum32())
+}
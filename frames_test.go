@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func testFrames() []*image.NRGBA {
+	var frames []*image.NRGBA
+	for _, c := range []color.NRGBA{{R: 0xff, A: 0xff}, {G: 0xff, A: 0xff}, {B: 0xff, A: 0xff}} {
+		img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+		for i := 0; i < 16; i++ {
+			img.SetNRGBA(i%4, i/4, c)
+		}
+		frames = append(frames, img)
+	}
+	return frames
+}
+
+func TestGIFEncoder(t *testing.T) {
+	tests := []struct {
+		loop      int
+		loopCount int
+	}{
+		{loop: 0, loopCount: 0},
+		{loop: 1, loopCount: -1},
+		{loop: 3, loopCount: 2},
+	}
+
+	for _, tt := range tests {
+		e := newGIFEncoder(tt.loop, false)
+		for _, img := range testFrames() {
+			if err := e.add(img, 0.05); err != nil {
+				t.Fatal(err)
+			}
+		}
+		data, err := e.encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(g.Image) != 3 || g.Delay[0] != 5 || g.LoopCount != tt.loopCount {
+			t.Errorf("loop %d: frames = %d, delay = %d, LoopCount = %d", tt.loop, len(g.Image), g.Delay[0], g.LoopCount)
+		}
+		if r, _, _, _ := g.Image[0].At(0, 0).RGBA(); r != 0xffff {
+			t.Errorf("loop %d: first frame is not red", tt.loop)
+		}
+	}
+}
+
+func TestAPNGEncoder(t *testing.T) {
+	e := newAPNGEncoder(2, false)
+	for _, img := range testFrames() {
+		if err := e.add(img, 0.25); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := e.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// APNG に対応していないデコーダーでは最初のフレームが表示される
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, g, _, _ := img.At(0, 0).RGBA(); r != 0xffff || g != 0 {
+		t.Error("first frame is not red")
+	}
+
+	chunks := make(map[string]int)
+	var actl []byte
+	for b := data[8:]; len(b) >= 12; {
+		length := binary.BigEndian.Uint32(b)
+		name := string(b[4:8])
+		chunks[name]++
+		if name == "acTL" {
+			actl = b[8 : 8+length]
+		}
+		b = b[12+length:]
+	}
+	if chunks["fcTL"] != 3 || chunks["fdAT"] != 2 || chunks["IDAT"] != 1 {
+		t.Errorf("chunks = %v", chunks)
+	}
+	if binary.BigEndian.Uint32(actl) != 3 || binary.BigEndian.Uint32(actl[4:]) != 2 {
+		t.Errorf("acTL = %v", actl)
+	}
+}
+
+func TestAPNGEncoderOpacity(t *testing.T) {
+	// 不透明なフレームと半透明の画素を含むフレームを混ぜても、同じ IHDR で書き込める
+	frames := testFrames()
+	frames[1].SetNRGBA(1, 2, color.NRGBA{G: 0xff, A: 0x80})
+	frames[2].SetNRGBA(3, 3, color.NRGBA{})
+
+	e := newAPNGEncoder(0, true)
+	for _, img := range frames {
+		if err := e.add(img, 0.25); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := e.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// IHDR と各フレームのデータから単独の PNG を組み立て、元の画素と比べる
+	var (
+		header []byte
+		i      int
+	)
+	for b := data[8:]; len(b) >= 12; {
+		length := binary.BigEndian.Uint32(b)
+		name, chunk := string(b[4:8]), b[8:8+length]
+		b = b[12+length:]
+
+		switch name {
+		case "IHDR":
+			header = chunk
+			if chunk[8] != 8 || chunk[9] != 6 {
+				t.Fatalf("frames must be 8 bit RGBA, actual: depth %d, color type %d", chunk[8], chunk[9])
+			}
+			continue
+		case "IDAT":
+		case "fdAT":
+			chunk = chunk[4:]
+		default:
+			continue
+		}
+
+		buffer := new(bytes.Buffer)
+		buffer.WriteString("\x89PNG\r\n\x1a\n")
+		writePNGChunk(buffer, "IHDR", header)
+		writePNGChunk(buffer, "IDAT", chunk)
+		writePNGChunk(buffer, "IEND", nil)
+		img, err := png.Decode(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if got, want := color.NRGBAModel.Convert(img.At(x, y)), frames[i].NRGBAAt(x, y); got != want {
+					t.Errorf("frame %d (%d, %d) must be %v, actual: %v", i, x, y, want, got)
+				}
+			}
+		}
+		i++
+	}
+	if i != len(frames) {
+		t.Errorf("apng must have %d frames, actual: %d", len(frames), i)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"math"
+)
+
+type vector [3]float64
+
+// matrix 3x3 の回転行列
+type matrix [3][3]float64
+
+var identityMatrix = matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+
+// rotateVector glTF の rotation (x, y, z, w) でベクトルを回転する
+func rotateVector(r [4]float64, v vector) vector {
+	q := vector{r[0], r[1], r[2]}
+	t := cross(q, v)
+	for i := range t {
+		t[i] *= 2
+	}
+	u := cross(q, t)
+	return vector{
+		v[0] + r[3]*t[0] + u[0],
+		v[1] + r[3]*t[1] + u[1],
+		v[2] + r[3]*t[2] + u[2],
+	}
+}
+
+func dot(a, b vector) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// norm ベクトルの長さを求める
+func norm(v vector) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+func cross(a, b vector) vector {
+	return vector{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+// axisRotation 軸 (0: X, 1: Y, 2: Z) まわりに右手系で radian だけ回転する行列を取得する
+func axisRotation(axis int, radian float64) matrix {
+	c, s := math.Cos(radian), math.Sin(radian)
+	switch axis {
+	case 0:
+		return matrix{{1, 0, 0}, {0, c, -s}, {0, s, c}}
+	case 1:
+		return matrix{{c, 0, s}, {0, 1, 0}, {-s, 0, c}}
+	default:
+		return matrix{{c, -s, 0}, {s, c, 0}, {0, 0, 1}}
+	}
+}
+
+// mul 行列の積 m * n を求める
+func (m matrix) mul(n matrix) matrix {
+	var r matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				r[i][j] += m[i][k] * n[k][j]
+			}
+		}
+	}
+	return r
+}
+
+// apply ベクトルを行列で変換する
+func (m matrix) apply(v vector) vector {
+	var r vector
+	for i := 0; i < 3; i++ {
+		r[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+	}
+	return r
+}
+
+// transpose 転置行列 (回転行列の場合は逆行列) を取得する
+func (m matrix) transpose() matrix {
+	var r matrix
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			r[i][j] = m[j][i]
+		}
+	}
+	return r
+}
+
+func add(a, b vector) vector {
+	return vector{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+func scaled(v vector, k float64) vector {
+	return vector{v[0] * k, v[1] * k, v[2] * k}
+}
+
+// axisAngleQuaternion 単位ベクトル axis まわりに右手系で radian だけ回転する glTF の rotation (x, y, z, w) を取得する
+func axisAngleQuaternion(axis vector, radian float64) [4]float64 {
+	s := math.Sin(radian / 2)
+	return [4]float64{axis[0] * s, axis[1] * s, axis[2] * s, math.Cos(radian / 2)}
+}
+
+// matrixQuaternion 回転行列を glTF の rotation (x, y, z, w) に変換する
+func matrixQuaternion(m matrix) [4]float64 {
+	trace := m[0][0] + m[1][1] + m[2][2]
+	switch {
+	case trace > 0:
+		s := 2 * math.Sqrt(trace+1)
+		return [4]float64{(m[2][1] - m[1][2]) / s, (m[0][2] - m[2][0]) / s, (m[1][0] - m[0][1]) / s, s / 4}
+	case m[0][0] > m[1][1] && m[0][0] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[0][0]-m[1][1]-m[2][2])
+		return [4]float64{s / 4, (m[0][1] + m[1][0]) / s, (m[0][2] + m[2][0]) / s, (m[2][1] - m[1][2]) / s}
+	case m[1][1] > m[2][2]:
+		s := 2 * math.Sqrt(1+m[1][1]-m[0][0]-m[2][2])
+		return [4]float64{(m[0][1] + m[1][0]) / s, s / 4, (m[1][2] + m[2][1]) / s, (m[0][2] - m[2][0]) / s}
+	default:
+		s := 2 * math.Sqrt(1+m[2][2]-m[0][0]-m[1][1])
+		return [4]float64{(m[0][2] + m[2][0]) / s, (m[1][2] + m[2][1]) / s, s / 4, (m[1][0] - m[0][1]) / s}
+	}
+}
+
+// slerp glTF の rotation (x, y, z, w) を球面線形補間する
+func slerp(a, b [4]float64, t float64) [4]float64 {
+	d := a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+	if d < 0 {
+		b, d = [4]float64{-b[0], -b[1], -b[2], -b[3]}, -d
+	}
+
+	ka, kb := 1-t, t
+	if d < 0.9995 {
+		theta := math.Acos(d)
+		ka, kb = math.Sin((1-t)*theta)/math.Sin(theta), math.Sin(t*theta)/math.Sin(theta)
+	}
+
+	var r [4]float64
+	length := 0.0
+	for i := range r {
+		r[i] = ka*a[i] + kb*b[i]
+		length += r[i] * r[i]
+	}
+	length = math.Sqrt(length)
+	for i := range r {
+		r[i] /= length
+	}
+	return r
+}
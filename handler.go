@@ -2,9 +2,14 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/go-chi/render"
@@ -12,13 +17,45 @@ import (
 
 const (
 	ContentTypeGltf = "model/gltf+json"
+	ContentTypeSVG  = "image/svg+xml"
+	ContentTypePNG  = "image/png"
+	ContentTypeGIF  = "image/gif"
+	ContentTypeJPEG = "image/jpeg"
+	ContentTypeZip  = "application/zip"
+	ContentType3MF  = "model/3mf"
+	ContentTypeUSDZ = "model/vnd.usdz+zip"
+	ContentTypeAPNG = "image/apng"
+)
+
+const (
+	formatGltf = "gltf"
+	formatSVG  = "svg"
+	formatOBJ  = "obj"
+	format3MF  = "3mf"
+	formatSTL  = "stl"
+	formatUSDZ = "usdz"
+	formatGIF  = "gif"
+	formatAPNG = "apng"
+	formatPNG  = "png"
+)
+
+const (
+	viewNet = "net"
 )
 
 type request struct {
 	Algorithm []string
+	Format    string
+	View      string
+	Size      float64
+	Animation *animationOptions
+	Render    *renderOptions
+	Moves     *float64 // 途中まで適用する手数。小数部分は次の手の途中
+	Steps     []int    // ステップごとの終わりの手数。steps=1 の場合のみ
+	Style     cubeStyle
 }
 
-var algRegex = regexp.MustCompile("[UDFBLRudlrfb'2 ]*")
+var algRegex = regexp.MustCompile("[UDFBLR'2 ]*")
 
 func getCubeHandler(w http.ResponseWriter, r *http.Request) {
 	req, err := bindGetCubeHandlerRequest(r.URL.Query())
@@ -28,13 +65,80 @@ func getCubeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	data, err := generateCube(req.Algorithm)
+	writeCube(w, r, req)
+}
+
+// postCubeHandler GET /cube.gltf と同じパラメーターに加えて、本文の PNG をステッカーに貼った glTF を返す
+func postCubeHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := bindPostCubeHandlerRequest(r.URL.Query(), http.MaxBytesReader(w, r.Body, maxImageSize))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.PlainText(w, r, err.Error())
+		return
+	}
+
+	writeCube(w, r, req)
+}
+
+// writeCube リクエストのフォーマットでキューブを生成して書き込む
+func writeCube(w http.ResponseWriter, r *http.Request, req *request) {
+	var (
+		data        []byte
+		contentType string
+		err         error
+	)
+	switch req.Format {
+	case formatSVG:
+		data, err = generateNetSVG(req.Algorithm)
+		contentType = ContentTypeSVG
+	case formatOBJ:
+		data, err = generateOBJ(req.Algorithm)
+		contentType = ContentTypeZip
+		w.Header().Set("Content-Disposition", `attachment; filename="cube.zip"`)
+	case format3MF:
+		data, err = generate3MF(req.Algorithm, req.Size)
+		contentType = ContentType3MF
+		w.Header().Set("Content-Disposition", `attachment; filename="cube.3mf"`)
+	case formatSTL:
+		data, err = generateSTL(req.Algorithm, req.Size)
+		contentType = ContentTypeZip
+		w.Header().Set("Content-Disposition", `attachment; filename="cube-stl.zip"`)
+	case formatUSDZ:
+		data, err = generateUSDZ(req.Algorithm)
+		contentType = ContentTypeUSDZ
+	case formatGIF:
+		data, err = generateAnimatedImage(req.Format, req.Algorithm, *req.Animation, *req.Render)
+		contentType = ContentTypeGIF
+	case formatAPNG:
+		data, err = generateAnimatedImage(req.Format, req.Algorithm, *req.Animation, *req.Render)
+		contentType = ContentTypeAPNG
+	case formatPNG:
+		moves := float64(len(req.Algorithm))
+		if req.Moves != nil {
+			moves = *req.Moves
+		}
+		data, err = generateCubeImage(req.Algorithm, moves, *req.Render)
+		contentType = ContentTypePNG
+	default:
+		switch {
+		case req.Animation != nil:
+			data, err = generateAnimatedCube(req.Algorithm, *req.Animation, req.Style)
+		case req.Moves != nil:
+			data, err = generateCubeAt(req.Algorithm, *req.Moves, req.Style)
+		case req.Steps != nil:
+			data, err = generateCubeSteps(req.Algorithm, req.Steps, req.Style)
+		default:
+			data, err = generateCube(req.Algorithm, req.Style)
+		}
+		contentType = ContentTypeGltf
+	}
 	if err != nil {
 		render.Status(r, http.StatusInternalServerError)
 		render.PlainText(w, r, err.Error())
+		return
 	}
 
-	w.Header().Set("Content-Type", ContentTypeGltf)
+	w.Header().Set("Content-Type", contentType)
 	_, _ = w.Write(data)
 }
 
@@ -44,30 +148,805 @@ var allowedAlg = [18]string{
 	"U2", "D2", "F2", "B2", "L2", "R2",
 }
 
+// validateMove 回転記号が allowedAlg のいずれかであるかを、大文字・小文字を区別して確認する
+func validateMove(a string) error {
+	for _, allowed := range allowedAlg {
+		if a == allowed {
+			return nil
+		}
+	}
+	return errors.New(`alg must only use "U D F B L R U' D' F' B' L' R' U2 D2 F2 B2 L2 R2"`)
+}
+
 func bindGetCubeHandlerRequest(urlValues url.Values) (*request, error) {
 	req := new(request)
+	var (
+		timestamps map[int]float64
+		steps      []int
+	)
 
 	if alg := urlValues.Get("alg"); alg != "" {
 		if !algRegex.Match([]byte(alg)) {
-			return nil, errors.New(`alg must be the following pattern: "[UDFBLRudlrfb'2 ]*"`)
+			return nil, errors.New(`alg must be the following pattern: "[UDFBLR'2 ]*"`)
+		}
+
+		// (R U R' U') のように括弧で囲んだ手は steps=1 で 1 ステップになる
+		algSlice, ends, err := parseAlgGroups(strings.Split(alg, " "))
+		if err != nil {
+			return nil, err
 		}
+		steps = ends
 
-		algSlice := strings.Split(alg, " ")
-		for _, a := range algSlice {
-			ok := false
-			upper := strings.ToUpper(a)
-			for _, allowed := range allowedAlg {
-				if upper == allowed {
-					ok = true
+		for i, a := range algSlice {
+			// アニメーションでは R@1.5 のように手の開始時刻 (秒) を付けられる
+			if j := strings.IndexByte(a, '@'); j >= 0 {
+				t, err := strconv.ParseFloat(a[j+1:], 64)
+				if err != nil || !(t >= 0) || math.IsInf(t, 1) {
+					return nil, fmt.Errorf("timestamp of alg must be a non-negative number of seconds: %s", a)
 				}
+				if timestamps == nil {
+					timestamps = make(map[int]float64)
+				}
+				timestamps[i] = t
+				a = a[:j]
+				algSlice[i] = a
 			}
-			if !ok {
-				return nil, errors.New(`alg must only use "U D F B L R U' D' F' B' L' R' U2 D2 F2 B2 L2 R2"`)
+
+			if err := validateMove(a); err != nil {
+				return nil, err
 			}
 		}
 
 		req.Algorithm = algSlice
 	}
 
+	switch req.Format = urlValues.Get("format"); req.Format {
+	case "", formatGltf:
+		req.Format = formatGltf
+	case formatSVG, formatOBJ, format3MF, formatSTL, formatUSDZ, formatGIF, formatAPNG, formatPNG:
+	default:
+		return nil, errors.New(`format must be one of "gltf svg obj 3mf stl usdz gif apng png"`)
+	}
+
+	switch req.View = urlValues.Get("view"); req.View {
+	case "":
+		if req.Format == formatSVG {
+			req.View = viewNet
+		}
+	case viewNet:
+		if req.Format != formatSVG {
+			return nil, errors.New(`view "net" is only available with format "svg"`)
+		}
+	default:
+		if req.Format != formatGltf {
+			return nil, errors.New(`view must be "net"`)
+		}
+		view, err := parseCubeView(req.View, urlValues.Get("r"))
+		if err != nil {
+			return nil, err
+		}
+		req.Style.View = view
+	}
+
+	req.Size = defaultCubeSize
+	if mm := urlValues.Get("mm"); mm != "" {
+		if req.Format != format3MF && req.Format != formatSTL {
+			return nil, errors.New(`mm is only available with format "3mf" or "stl"`)
+		}
+		size, err := strconv.ParseFloat(mm, 64)
+		if err != nil || !(size >= 1 && size <= maxCubeSize) {
+			return nil, fmt.Errorf("mm must be between 1 and %g", maxCubeSize)
+		}
+		req.Size = size
+	}
+
+	// gif と apng は常に回転記号を再生する
+	animated := req.Format == formatGIF || req.Format == formatAPNG
+	switch animate := urlValues.Get("animate"); animate {
+	case "", "0":
+	case "1":
+		if req.Format != formatGltf && !animated {
+			return nil, errors.New(`animate is only available with format "gltf gif apng"`)
+		}
+		animated = true
+	default:
+		return nil, errors.New("animate must be 0 or 1")
+	}
+
+	if animated {
+		options, err := bindAnimationOptions(urlValues)
+		if err != nil {
+			return nil, err
+		}
+		options.Timestamps = timestamps
+		// 時刻の指定が前の手と重なる場合は、生成する前にリクエストの誤りとして返す
+		if _, err := moveStarts(len(req.Algorithm), *options); err != nil {
+			return nil, err
+		}
+		req.Animation = options
+	} else {
+		if timestamps != nil {
+			return nil, errors.New("timestamps of alg are only available with animate=1")
+		}
+		for _, key := range []string{"duration", "pause", "easing"} {
+			if urlValues.Get(key) != "" {
+				return nil, fmt.Errorf("%s is only available with animate=1", key)
+			}
+		}
+	}
+
+	switch req.Format {
+	case formatGIF, formatAPNG, formatPNG:
+		options, err := bindRenderOptions(urlValues)
+		if err != nil {
+			return nil, err
+		}
+		frames := 1
+		if req.Animation != nil {
+			if frames, err = animationFrameCount(req.Algorithm, *req.Animation, options.FrameRate); err != nil {
+				return nil, err
+			}
+		}
+		if err := checkRenderWork(options.Size, frames); err != nil {
+			return nil, err
+		}
+		req.Render = options
+	default:
+		for _, key := range []string{"size", "r", "bg"} {
+			// gltf の r は view=custom の回転として parseCubeView で読み取る
+			if key == "r" && req.Style.View != nil {
+				continue
+			}
+			if urlValues.Get(key) != "" {
+				return nil, fmt.Errorf(`%s is only available with format "png gif apng"`, key)
+			}
+		}
+	}
+	if req.Format != formatGIF && req.Format != formatAPNG {
+		for _, key := range []string{"fps", "loop"} {
+			if urlValues.Get(key) != "" {
+				return nil, fmt.Errorf(`%s is only available with format "gif apng"`, key)
+			}
+		}
+	}
+
+	moves, progress := urlValues.Get("t"), urlValues.Get("progress")
+	if moves != "" || progress != "" {
+		if (req.Format != formatGltf && req.Format != formatPNG) || req.Animation != nil {
+			return nil, errors.New(`t and progress are only available with format "gltf png" without animate=1`)
+		}
+		if moves != "" && progress != "" {
+			return nil, errors.New("t and progress cannot be used together")
+		}
+
+		count := float64(len(req.Algorithm))
+		var m float64
+		if moves != "" {
+			t, err := strconv.ParseFloat(moves, 64)
+			if err != nil || !(t >= 0 && t <= count) {
+				return nil, fmt.Errorf("t must be between 0 and the number of moves (%g)", count)
+			}
+			m = t
+		} else {
+			p, err := strconv.ParseFloat(progress, 64)
+			if err != nil || !(p >= 0 && p <= 1) {
+				return nil, errors.New("progress must be between 0 and 1")
+			}
+			m = p * count
+		}
+		req.Moves = &m
+	}
+
+	switch urlValues.Get("steps") {
+	case "", "0":
+	case "1":
+		if req.Format != formatGltf || req.Animation != nil || req.Moves != nil {
+			return nil, errors.New(`steps is only available with format "gltf" without animate, t and progress`)
+		}
+		req.Steps = steps
+		if req.Steps == nil {
+			req.Steps = []int{}
+		}
+	default:
+		return nil, errors.New("steps must be 0 or 1")
+	}
+
+	sch := urlValues.Get("sch")
+	if sch != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`sch is only available with format "gltf"`)
+		}
+		scheme, err := parseCubeScheme(sch)
+		if err != nil {
+			return nil, err
+		}
+		req.Style.Scheme = &scheme
+	}
+
+	// palette は western の色を置き換え、sch の配色の名前と組み合わせられる
+	if palette := urlValues.Get("palette"); palette != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`palette is only available with format "gltf"`)
+		}
+		if sch != "" && !isSchemePreset(sch) {
+			return nil, errors.New("palette must not be given with custom sch colors")
+		}
+		scheme, err := parsePalette(palette)
+		if err != nil {
+			return nil, err
+		}
+		if sch != "" {
+			if scheme, err = parseSchemeOn(scheme, sch); err != nil {
+				return nil, err
+			}
+		}
+		req.Style.Scheme = &scheme
+	}
+
+	if stage := urlValues.Get("stage"); stage != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`stage is only available with format "gltf"`)
+		}
+		mask, err := stageMask(stage)
+		if err != nil {
+			return nil, err
+		}
+		req.Style.Mask = &mask
+	}
+
+	if fc := strings.ToLower(urlValues.Get("fc")); fc != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`fc is only available with format "gltf"`)
+		}
+		colors, err := parseFaceletColors(fc)
+		if err != nil {
+			return nil, err
+		}
+		req.Style.Colors = colors
+	}
+
+	if highlight := urlValues.Get("highlight"); highlight != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`highlight is only available with format "gltf"`)
+		}
+		tests, err := parseHighlight(highlight)
+		if err != nil {
+			return nil, err
+		}
+		if req.Style.Highlight, err = highlightNodes(req.Algorithm, tests); err != nil {
+			return nil, err
+		}
+	}
+
+	switch arrows := urlValues.Get("arrows"); arrows {
+	case "":
+	case arrowsAuto:
+		if req.Format != formatGltf || req.Animation != nil || req.Moves != nil || req.Steps != nil {
+			return nil, errors.New(`arrows is only available with format "gltf" without animate, t, progress and steps`)
+		}
+		req.Style.Arrows = true
+	default:
+		return nil, errors.New(`arrows must be "auto"`)
+	}
+
+	if explode := urlValues.Get("explode"); explode != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`explode is only available with format "gltf"`)
+		}
+		e, err := strconv.ParseFloat(explode, 64)
+		if err != nil || !(e >= 0 && e <= maxExplode) {
+			return nil, fmt.Errorf("explode must be between 0 and %g", maxExplode)
+		}
+		req.Style.Explode = e
+	}
+
+	switch urlValues.Get("mirrors") {
+	case "", "0":
+		if urlValues.Get("mirrordist") != "" {
+			return nil, errors.New("mirrordist is only available with mirrors=1")
+		}
+	case "1":
+		if req.Format != formatGltf || req.Animation != nil || req.Moves != nil || req.Steps != nil {
+			return nil, errors.New(`mirrors is only available with format "gltf" without animate, t, progress and steps`)
+		}
+		req.Style.Mirrors = defaultMirrorDistance
+		if distance := urlValues.Get("mirrordist"); distance != "" {
+			d, err := strconv.ParseFloat(distance, 64)
+			if err != nil || !(d > 0 && d <= maxMirrorDistance) {
+				return nil, fmt.Errorf("mirrordist must be greater than 0 and at most %g", maxMirrorDistance)
+			}
+			req.Style.Mirrors = d
+		}
+	default:
+		return nil, errors.New("mirrors must be 0 or 1")
+	}
+
+	if hide := urlValues.Get("hide"); hide != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`hide is only available with format "gltf"`)
+		}
+		tests, err := parseHighlight(hide)
+		if err != nil {
+			return nil, err
+		}
+		if req.Style.Hide, err = highlightNodes(req.Algorithm, tests); err != nil {
+			return nil, err
+		}
+	}
+
+	switch urlValues.Get("xray") {
+	case "", "0":
+	case "1":
+		if req.Format != formatGltf {
+			return nil, errors.New(`xray is only available with format "gltf"`)
+		}
+		req.Style.XRay = true
+	default:
+		return nil, errors.New("xray must be 0 or 1")
+	}
+
+	switch urlValues.Get("shading") {
+	case "", "unlit":
+	case "pbr":
+		if req.Format != formatGltf {
+			return nil, errors.New(`shading is only available with format "gltf"`)
+		}
+		req.Style.PBR = true
+	default:
+		return nil, errors.New("shading must be unlit or pbr")
+	}
+	switch urlValues.Get("lights") {
+	case "", "0":
+	case "1":
+		if !req.Style.PBR {
+			return nil, errors.New(`lights is only available with shading "pbr"`)
+		}
+		req.Style.Lights = true
+	default:
+		return nil, errors.New("lights must be 0 or 1")
+	}
+	switch urlValues.Get("ground") {
+	case "", "0":
+	case "1":
+		if !req.Style.PBR {
+			return nil, errors.New(`ground is only available with shading "pbr"`)
+		}
+		req.Style.Ground = true
+	default:
+		return nil, errors.New("ground must be 0 or 1")
+	}
+
+	if image := urlValues.Get("image"); image != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`image is only available with format "gltf"`)
+		}
+		uri, err := imageURL(image)
+		if err != nil {
+			return nil, err
+		}
+		if req.Style.Image, err = bindStickerImage(urlValues, uri); err != nil {
+			return nil, err
+		}
+	}
+
+	switch urlValues.Get("symbols") {
+	case "", "0":
+	case "1":
+		if req.Format != formatGltf {
+			return nil, errors.New(`symbols is only available with format "gltf"`)
+		}
+		req.Style.Symbols = true
+	default:
+		return nil, errors.New("symbols must be 0 or 1")
+	}
+
+	if labels := urlValues.Get("labels"); labels != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`labels is only available with format "gltf"`)
+		}
+		l, err := parseLabels(labels)
+		if err != nil {
+			return nil, err
+		}
+		req.Style.Labels = l
+	}
+
+	switch urlValues.Get("variants") {
+	case "", "0":
+	case "1":
+		if req.Format != formatGltf {
+			return nil, errors.New(`variants is only available with format "gltf"`)
+		}
+		variants, err := schemeVariants(sch)
+		if err != nil {
+			return nil, err
+		}
+		req.Style.Variants = variants
+	default:
+		return nil, errors.New("variants must be 0 or 1")
+	}
+
+	if lang := urlValues.Get("describe"); lang != "" {
+		if req.Format != formatGltf {
+			return nil, errors.New(`describe is only available with format "gltf"`)
+		}
+		if _, ok := describeTexts[lang]; !ok {
+			return nil, fmt.Errorf("describe must be one of %s", strings.Join(describeLangs, ", "))
+		}
+		req.Style.Describe = lang
+	}
+
+	return req, nil
+}
+
+// bindPostCubeHandlerRequest GET /cube.gltf のパラメーターと、ステッカーに貼る PNG の本文を読み取る
+func bindPostCubeHandlerRequest(urlValues url.Values, body io.Reader) (*request, error) {
+	if urlValues.Get("image") != "" {
+		return nil, errors.New("image must not be given with an uploaded image")
+	}
+	req, err := bindGetCubeHandlerRequest(urlValues)
+	if err != nil {
+		return nil, err
+	}
+	if req.Format != formatGltf {
+		return nil, errors.New(`uploaded image is only available with format "gltf"`)
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+	uri, err := imageDataURI(data)
+	if err != nil {
+		return nil, err
+	}
+	if req.Style.Image, err = bindStickerImage(urlValues, uri); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// bindStickerImage imageface・imagearea パラメーターから、uri の画像を貼る場所を決める
+func bindStickerImage(urlValues url.Values, uri string) (*stickerImage, error) {
+	img := &stickerImage{URI: uri, Face: FaceU}
+	if face := urlValues.Get("imageface"); face != "" {
+		f, err := parseImageFace(face)
+		if err != nil {
+			return nil, err
+		}
+		img.Face = f
+	}
+	switch urlValues.Get("imagearea") {
+	case "", imageAreaCenter:
+	case imageAreaFace:
+		img.Whole = true
+	default:
+		return nil, fmt.Errorf("imagearea must be %q or %q", imageAreaCenter, imageAreaFace)
+	}
+	return img, nil
+}
+
+func bindAnimationOptions(urlValues url.Values) (*animationOptions, error) {
+	options := &animationOptions{
+		Duration: defaultMoveDuration,
+		Easing:   easingEaseInOut,
+	}
+
+	if duration := urlValues.Get("duration"); duration != "" {
+		d, err := strconv.ParseFloat(duration, 64)
+		if err != nil || !(d > 0 && d <= maxMoveDuration) {
+			return nil, fmt.Errorf("duration must be greater than 0 and at most %g", maxMoveDuration)
+		}
+		options.Duration = d
+	}
+
+	if pause := urlValues.Get("pause"); pause != "" {
+		p, err := strconv.ParseFloat(pause, 64)
+		if err != nil || !(p >= 0 && p <= maxMovePause) {
+			return nil, fmt.Errorf("pause must be between 0 and %g", maxMovePause)
+		}
+		options.Pause = p
+	}
+
+	if easing := urlValues.Get("easing"); easing != "" {
+		if _, ok := easings[easing]; !ok {
+			return nil, errors.New(`easing must be one of "linear ease-in ease-out ease-in-out"`)
+		}
+		options.Easing = easing
+	}
+
+	return options, nil
+}
+
+func bindRenderOptions(urlValues url.Values) (*renderOptions, error) {
+	options := &renderOptions{
+		Size:       defaultAnimatedSize,
+		FrameRate:  defaultFrameRate,
+		Background: colorCodes['w'],
+	}
+
+	if size := urlValues.Get("size"); size != "" {
+		s, err := strconv.Atoi(size)
+		if err != nil || s < 1 || s > maxAnimatedSize {
+			return nil, fmt.Errorf("size must be between 1 and %d", maxAnimatedSize)
+		}
+		options.Size = s
+	}
+
+	if fps := urlValues.Get("fps"); fps != "" {
+		f, err := strconv.Atoi(fps)
+		if err != nil || f < 1 || f > maxFrameRate {
+			return nil, fmt.Errorf("fps must be between 1 and %d", maxFrameRate)
+		}
+		options.FrameRate = f
+	}
+
+	if loop := urlValues.Get("loop"); loop != "" {
+		l, err := strconv.Atoi(loop)
+		if err != nil || l < 0 || l > 0xffff {
+			return nil, fmt.Errorf("loop must be between 0 and %d", 0xffff)
+		}
+		options.Loop = l
+	}
+
+	rotation := urlValues.Get("r")
+	if rotation == "" {
+		rotation = visualCubeDefaultRotation
+	}
+	m, err := parseVisualCubeRotation(rotation)
+	if err != nil {
+		return nil, err
+	}
+	options.Rotation = m
+
+	if bg := urlValues.Get("bg"); bg != "" {
+		c, err := parseColor(bg)
+		if err != nil {
+			return nil, err
+		}
+		options.Background = c
+	}
+
+	return options, nil
+}
+
+// sheetRequest POST /sheet のリクエストボディ
+type sheetRequest struct {
+	Algs []struct {
+		Alg   string `json:"alg"`
+		Label string `json:"label"`
+	} `json:"algs"`
+	Columns int `json:"columns"`
+}
+
+// maxSheetRequestSize POST /sheet のリクエストボディの上限 (バイト)
+const maxSheetRequestSize = 1 << 20
+
+func postSheetHandler(w http.ResponseWriter, r *http.Request) {
+	cubes, columns, err := bindSheetRequest(http.MaxBytesReader(w, r.Body, maxSheetRequestSize))
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.PlainText(w, r, err.Error())
+		return
+	}
+
+	data, err := generateSheet(cubes, columns)
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.PlainText(w, r, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeGltf)
+	_, _ = w.Write(data)
+}
+
+func bindSheetRequest(body io.Reader) ([]sheetCube, int, error) {
+	var req sheetRequest
+	if err := render.DecodeJSON(body, &req); err != nil {
+		return nil, 0, fmt.Errorf("invalid request body: %v", err)
+	}
+
+	if len(req.Algs) == 0 || len(req.Algs) > maxSheetCubes {
+		return nil, 0, fmt.Errorf("algs must have between 1 and %d items", maxSheetCubes)
+	}
+	if req.Columns < 0 || req.Columns > maxSheetCubes {
+		return nil, 0, fmt.Errorf("columns must be between 0 and %d", maxSheetCubes)
+	}
+
+	cubes := make([]sheetCube, len(req.Algs))
+	for i, a := range req.Algs {
+		moves := strings.Fields(a.Alg)
+		for _, m := range moves {
+			if err := validateMove(m); err != nil {
+				return nil, 0, fmt.Errorf("algs[%d]: %v", i, err)
+			}
+		}
+		cubes[i] = sheetCube{Algorithm: moves, Label: a.Label}
+	}
+
+	return cubes, req.Columns, nil
+}
+
+// getDescribeHandler 回転記号を適用したキューブの状態を説明する文章を返す
+func getDescribeHandler(w http.ResponseWriter, r *http.Request) {
+	alg, lang, err := bindDescribeRequest(r.URL.Query())
+	if err != nil {
+		render.Status(r, http.StatusBadRequest)
+		render.PlainText(w, r, err.Error())
+		return
+	}
+
+	text, err := describeCube(alg, lang, cubeStyle{})
+	if err != nil {
+		render.Status(r, http.StatusInternalServerError)
+		render.PlainText(w, r, err.Error())
+		return
+	}
+
+	render.PlainText(w, r, text)
+}
+
+func bindDescribeRequest(urlValues url.Values) ([]string, string, error) {
+	alg, err := parseVisualCubeAlg(urlValues.Get("alg"))
+	if err != nil {
+		return nil, "", err
+	}
+
+	lang := urlValues.Get("lang")
+	if lang == "" {
+		lang = langEnglish
+	}
+	if _, ok := describeTexts[lang]; !ok {
+		return nil, "", fmt.Errorf("lang must be one of %s", strings.Join(describeLangs, ", "))
+	}
+
+	return alg, lang, nil
+}
+
+var imageContentTypes = map[string]string{
+	imageFormatSVG:  ContentTypeSVG,
+	imageFormatPNG:  ContentTypePNG,
+	imageFormatGIF:  ContentTypeGIF,
+	imageFormatJPEG: ContentTypeJPEG,
+}
+
+// getVisualCubeHandler VisualCube 互換の画像を返すハンドラーを取得する。format が空の場合は fmt パラメーターで指定する
+func getVisualCubeHandler(format string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, err := bindVisualCubeRequest(r.URL.Query(), format)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.PlainText(w, r, err.Error())
+			return
+		}
+
+		data, err := generateVisualCube(req)
+		if err != nil {
+			render.Status(r, http.StatusInternalServerError)
+			render.PlainText(w, r, err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", imageContentTypes[req.Format])
+		_, _ = w.Write(data)
+	}
+}
+
+func bindVisualCubeRequest(urlValues url.Values, format string) (*visualCubeRequest, error) {
+	req := &visualCubeRequest{
+		Format:     format,
+		View:       visualCubeViewNormal,
+		Scheme:     defaultScheme(),
+		Background: colorCodes['w'],
+		Size:       visualCubeDefaultSize,
+	}
+
+	if req.Format == "" {
+		switch f := urlValues.Get("fmt"); f {
+		case "":
+			req.Format = imageFormatPNG
+		case "jpeg":
+			req.Format = imageFormatJPEG
+		default:
+			if _, ok := imageContentTypes[f]; !ok {
+				return nil, errors.New(`fmt must be one of "svg png gif jpg"`)
+			}
+			req.Format = f
+		}
+	}
+
+	if pzl := urlValues.Get("pzl"); pzl != "" && pzl != "3" {
+		return nil, errors.New("pzl must be 3")
+	}
+
+	if c := urlValues.Get("case"); c != "" {
+		alg, err := parseVisualCubeAlg(c)
+		if err != nil {
+			return nil, err
+		}
+		req.Algorithm = invertAlg(alg)
+	}
+	if a := urlValues.Get("alg"); a != "" {
+		alg, err := parseVisualCubeAlg(a)
+		if err != nil {
+			return nil, err
+		}
+		req.Algorithm = append(req.Algorithm, alg...)
+	}
+
+	if fd := strings.ToLower(urlValues.Get("fd")); fd != "" {
+		if len(fd) != faceletCount || strings.Trim(fd, "urfdlbnt") != "" {
+			return nil, fmt.Errorf(`fd must be %d characters of "urfdlbnt"`, faceletCount)
+		}
+		req.Definition = fd
+	}
+
+	if fc := strings.ToLower(urlValues.Get("fc")); fc != "" {
+		colors, err := parseFaceletColors(fc)
+		if err != nil {
+			return nil, err
+		}
+		req.Colors = colors
+	}
+
+	if sch := urlValues.Get("sch"); sch != "" {
+		scheme, err := parseCubeScheme(sch)
+		if err != nil {
+			return nil, err
+		}
+		req.Scheme = scheme
+	}
+
+	switch view := urlValues.Get("view"); view {
+	case "", visualCubeViewNormal:
+	case visualCubeViewPlan, visualCubeViewTrans:
+		req.View = view
+	default:
+		return nil, errors.New(`view must be one of "plan trans"`)
+	}
+
+	if stage := urlValues.Get("stage"); stage != "" {
+		mask, err := stageMask(stage)
+		if err != nil {
+			return nil, err
+		}
+		req.Mask = mask
+	}
+
+	rotation := urlValues.Get("r")
+	if rotation == "" {
+		rotation = visualCubeDefaultRotation
+	}
+	m, err := parseVisualCubeRotation(rotation)
+	if err != nil {
+		return nil, err
+	}
+	req.Rotation = m
+
+	if arw := urlValues.Get("arw"); arw != "" {
+		arrows, err := parseArrows(arw)
+		if err != nil {
+			return nil, err
+		}
+		req.Arrows = arrows
+	}
+
+	if bg := urlValues.Get("bg"); bg != "" {
+		c, err := parseColor(bg)
+		if err != nil {
+			return nil, err
+		}
+		req.Background = c
+	}
+
+	if size := urlValues.Get("size"); size != "" {
+		s, err := strconv.Atoi(size)
+		if err != nil || s < 1 || s > visualCubeMaxSize {
+			return nil, fmt.Errorf("size must be between 1 and %d", visualCubeMaxSize)
+		}
+		req.Size = s
+	}
+
 	return req, nil
 }
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidateMove(t *testing.T) {
+	tests := []struct {
+		move  string
+		valid bool
+	}{
+		{move: "R", valid: true},
+		{move: "U'", valid: true},
+		{move: "F2", valid: true},
+		{move: "r"},
+		{move: "u'"},
+		{move: "x"},
+		{move: "R3"},
+	}
+	for _, tt := range tests {
+		if err := validateMove(tt.move); (err == nil) != tt.valid {
+			t.Errorf("%s: valid must be %v, actual: %v", tt.move, tt.valid, err)
+		}
+	}
+}
+
+func TestGetCubeHandlerTimestamps(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		// message 400 の本文に含まれる文字列
+		message string
+	}{
+		{query: "animate=1&alg=R@0+U@1", status: http.StatusOK},
+		{query: "animate=1&alg=R@0.5+U", status: http.StatusOK},
+		{query: "animate=1&alg=R@1+U@0", status: http.StatusBadRequest, message: "move 2 starts at 0s before the previous move ends at 1.5s"},
+		{query: "animate=1&alg=R+U@0.2", status: http.StatusBadRequest, message: "move 2 starts at 0.2s"},
+		{query: "animate=1&duration=0.1&alg=R+U@0.2", status: http.StatusOK},
+		{query: "format=gif&size=64&alg=R+U@0.2", status: http.StatusBadRequest, message: "move 2 starts at 0.2s"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		getCubeHandler(w, httptest.NewRequest(http.MethodGet, "/cube.gltf?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status must be %d, actual: %d %s", tt.query, tt.status, w.Code, w.Body)
+			continue
+		}
+		if !strings.Contains(w.Body.String(), tt.message) {
+			t.Errorf("%s: body must contain %q, actual: %s", tt.query, tt.message, w.Body)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+// copyCubeDocument cube.gltf を読み込み、テストで書き換えるための複製を取得する
+func copyCubeDocument(t *testing.T) *gltf.Document {
+	t.Helper()
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc gltf.Document
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		t.Fatal(err)
+	}
+	// deepcopy は埋め込みバッファーのデータを複製しない
+	doc.Buffers = gltfDoc.Buffers
+	return &doc
+}
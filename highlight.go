@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	highlightMisorientedEdges = "misoriented-edges"
+	highlightTwistedCorners   = "twisted-corners"
+	highlightUnsolved         = "unsolved"
+	highlightMovedByAlg       = "moved-by-alg"
+	highlightLayerPrefix      = "layer:"
+)
+
+// pieceKinds ピースの種類で選ぶセレクター
+var pieceKinds = map[string]pieceType{
+	"centers": centerPiece,
+	"edges":   edgePiece,
+	"corners": cornerPiece,
+}
+
+// highlightColor 強調したピースの本体の色
+var highlightColor = colorCodes['i']
+
+// pieceState 回転記号を適用した後のピースの状態
+type pieceState struct {
+	Home     vector // 完成状態での位置
+	Position vector // 現在の位置 (各成分が -1, 0, 1)
+	Kind     pieceType
+	Unsolved bool
+	Moved    bool // 回転記号の途中で一度でも動いたか。センターは含めない
+	// Misoriented エッジの向きが悪い (F/B 軸の EO)、またはコーナーがねじれている
+	Misoriented bool
+	// Twist 位置が変わらないピースの向きの変化。コーナーは外向きの軸まわりに右手系で 1 (-1 は逆向き)、エッジは反転で 1
+	Twist int
+}
+
+// pieceTest ピースを強調するかを判定する
+type pieceTest func(p pieceState) bool
+
+// layerPieces layer: で指定できる層と、層に含まれる位置の判定
+var layerPieces = map[string]pieceTest{
+	"U": func(p pieceState) bool { return p.Position[1] == 1 },
+	"D": func(p pieceState) bool { return p.Position[1] == -1 },
+	"F": func(p pieceState) bool { return p.Position[2] == 1 },
+	"B": func(p pieceState) bool { return p.Position[2] == -1 },
+	"R": func(p pieceState) bool { return p.Position[0] == 1 },
+	"L": func(p pieceState) bool { return p.Position[0] == -1 },
+	"M": func(p pieceState) bool { return p.Position[0] == 0 },
+	"E": func(p pieceState) bool { return p.Position[1] == 0 },
+	"S": func(p pieceState) bool { return p.Position[2] == 0 },
+}
+
+// parseHighlight highlight・hide パラメーター (カンマ区切りのピース名またはセレクター) を判定する関数に変換する。
+// ピース名は回転記号を適用した後の位置を表す
+func parseHighlight(s string) ([]pieceTest, error) {
+	var tests []pieceTest
+	for _, term := range strings.Split(s, ",") {
+		switch {
+		case term == highlightMisorientedEdges:
+			tests = append(tests, func(p pieceState) bool { return p.Kind == edgePiece && p.Misoriented })
+		case term == highlightTwistedCorners:
+			tests = append(tests, func(p pieceState) bool { return p.Kind == cornerPiece && p.Misoriented })
+		case term == highlightUnsolved:
+			tests = append(tests, func(p pieceState) bool { return p.Unsolved })
+		case term == highlightMovedByAlg:
+			tests = append(tests, func(p pieceState) bool { return p.Moved })
+		case pieceKinds[term] != 0:
+			kind := pieceKinds[term]
+			tests = append(tests, func(p pieceState) bool { return p.Kind == kind })
+		case strings.HasPrefix(term, highlightLayerPrefix):
+			test, ok := layerPieces[strings.TrimPrefix(term, highlightLayerPrefix)]
+			if !ok {
+				return nil, fmt.Errorf("unknown layer of highlight: %s", term)
+			}
+			tests = append(tests, test)
+		default:
+			position, ok := pieceSelector(term)
+			if !ok {
+				return nil, fmt.Errorf("unknown highlight: %s", term)
+			}
+			tests = append(tests, func(p pieceState) bool { return p.Position == position })
+		}
+	}
+	return tests, nil
+}
+
+// highlightNodes 回転記号を適用した後の状態で、いずれかの判定に当てはまるピースのノード名を取得する
+func highlightNodes(algorithm []string, tests []pieceTest) (map[string]bool, error) {
+	pieces, err := pieceStates(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]bool)
+	for name, p := range pieces {
+		for _, test := range tests {
+			if test(p) {
+				result[name] = true
+			}
+		}
+	}
+	return result, nil
+}
+
+// pieceStates 完成状態から回転記号を適用した後の、ノード名ごとのピースの状態を求める
+func pieceStates(algorithm []string) (map[string]pieceState, error) {
+	degrees, err := parseAlg(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	def, err := nodeToDefinition(gltfDoc.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	state, err := stateOf(def.nodes())
+	if err != nil {
+		return nil, err
+	}
+
+	// 1 手ずつ回し、途中でステッカーの位置が変わったピースを動いたものとする
+	moved := make(map[string]bool)
+	for _, degree := range degrees {
+		rotate(def, degree)
+		next, err := stateOf(def.nodes())
+		if err != nil {
+			return nil, err
+		}
+		for f := range next {
+			if next[f] != state[f] {
+				moved[stickers[next[f]].Node] = true
+			}
+		}
+		state = next
+	}
+
+	pieces := make(map[string]pieceState)
+	for facelet, i := range state {
+		s := stickers[i]
+		position, normal := faceletPiece(facelet)
+		home, homeNormal := faceletPiece(s.Home)
+
+		p := pieces[s.Node]
+		p.Home = home
+		p.Position = position
+		p.Kind = pieceKind(home)
+		p.Moved = moved[s.Node] && p.Kind != centerPiece
+		if facelet != s.Home {
+			p.Unsolved = true
+		}
+		// EO と CO の基準になるのは U/D 面の色のステッカー (U/D 面の色が無いエッジは F/B 面の色)
+		switch {
+		case p.Kind == edgePiece && homeNormal[1] != 0, p.Kind == edgePiece && home[1] == 0 && homeNormal[2] != 0:
+			p.Misoriented = !(normal[1] != 0 || (position[1] == 0 && normal[2] != 0))
+		case p.Kind == cornerPiece && homeNormal[1] != 0:
+			p.Misoriented = normal[1] == 0
+		}
+		if position == home && normal != homeNormal {
+			switch p.Kind {
+			case cornerPiece:
+				p.Twist = 1
+				if dot(cross(homeNormal, normal), home) < 0 {
+					p.Twist = -1
+				}
+			case edgePiece:
+				p.Twist = 1
+			}
+		}
+		pieces[s.Node] = p
+	}
+
+	return pieces, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHighlightNodes(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		alg       string
+		highlight string
+		count     int
+	}{
+		{alg: "", highlight: "unsolved", count: 0},
+		{alg: "R", highlight: "unsolved", count: 8},
+		{alg: "R R'", highlight: "unsolved", count: 0},
+		{alg: "R R'", highlight: "moved-by-alg", count: 8},
+		{alg: "R U", highlight: "moved-by-alg", count: 13},
+		{alg: "F", highlight: "misoriented-edges", count: 4},
+		{alg: "F2", highlight: "misoriented-edges", count: 0},
+		{alg: "R", highlight: "misoriented-edges", count: 0},
+		{alg: "R", highlight: "twisted-corners", count: 4},
+		{alg: "R2", highlight: "twisted-corners", count: 0},
+		{alg: "", highlight: "layer:U", count: 9},
+		{alg: "", highlight: "layer:M,layer:E", count: 8 + 8 - 2},
+		{alg: "R", highlight: "UFR,UF", count: 2},
+		{alg: "R", highlight: "centers", count: 6},
+		{alg: "", highlight: "edges,corners", count: 12 + 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg+" "+tt.highlight, func(t *testing.T) {
+			selectors, err := parseHighlight(tt.highlight)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var alg []string
+			if tt.alg != "" {
+				alg = strings.Split(tt.alg, " ")
+			}
+			nodes, err := highlightNodes(alg, selectors)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(nodes) != tt.count {
+				t.Errorf("highlighted pieces must be %d, actual: %v", tt.count, nodes)
+			}
+		})
+	}
+
+	nodes, err := highlightNodes([]string{"R"}, []pieceTest{func(p pieceState) bool { return p.Position == vector{1, 1, 1} }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nodes["DFR"] {
+		t.Errorf("DFR must be at UFR after R: %v", nodes)
+	}
+
+	for _, s := range []string{"layer:X", "UD", "faces"} {
+		if _, err := parseHighlight(s); err == nil {
+			t.Errorf("%s must be an error", s)
+		}
+	}
+}
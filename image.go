@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"math"
+	"net/url"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	imageAreaCenter = "center"
+	imageAreaFace   = "face"
+	// maxImageSize アップロードできる画像の最大バイト数
+	maxImageSize = 1 << 20
+)
+
+// stickerImage ステッカーに貼る画像
+type stickerImage struct {
+	URI  string // 画像の URL、または埋め込んだ data URI
+	Face Face   // 完成状態で画像を貼る面
+	// Whole 面の 9 枚のステッカーに 1 枚の画像を分けて貼る。false の場合はセンターのステッカーだけに貼る
+	Whole bool
+}
+
+// imageURL 参照する画像の URL を検証する。画像はビューアーが読み込むため、サーバーからは取得しない
+func imageURL(s string) (string, error) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("image must be an http or https URL: %s", s)
+	}
+	return u.String(), nil
+}
+
+// imageDataURI アップロードされた PNG を検証し、glTF に埋め込む data URI にする
+func imageDataURI(data []byte) (string, error) {
+	if len(data) > maxImageSize {
+		return "", fmt.Errorf("image must be at most %d bytes", maxImageSize)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("image must be a PNG: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// parseImageFace imageface パラメーター (U R F D L B) を面に変換する
+func parseImageFace(s string) (Face, error) {
+	for f, name := range faceNames {
+		if s == name {
+			return Face(f), nil
+		}
+	}
+	return FaceU, fmt.Errorf("imageface must be one of U R F D L B: %s", s)
+}
+
+// addStickerImage 画像を貼るステッカーのプリミティブに TEXCOORD_0 を追加し、画像をテクスチャーにしたマテリアルに置き換える。
+// UV は完成状態で面を展開図の向きに見たときの、貼るステッカー全体を囲む範囲を 0 - 1 にする。
+// ステッカーと一緒に動くため、面全体に貼るとセンターの向きやピースの入れ替わりが絵柄で分かる
+func addStickerImage(doc *gltf.Document, img *stickerImage) error {
+	axes := faceAxes[img.Face]
+	rotations := nodeRotations(gltfDoc.Nodes)
+	scales := make(map[string][3]float64, len(gltfDoc.Nodes))
+	for _, node := range gltfDoc.Nodes {
+		scales[node.Name] = node.ScaleOrDefault()
+	}
+
+	// stickerUV ステッカーの頂点ごとの、完成状態のワールド座標での面の右方向・下方向の位置
+	type stickerUV struct {
+		sticker Sticker
+		uv      [][2]float64
+	}
+	var (
+		targets  []stickerUV
+		min, max = [2]float64{math.Inf(1), math.Inf(1)}, [2]float64{math.Inf(-1), math.Inf(-1)}
+	)
+	for _, s := range stickers {
+		if s.Home/9 != int(img.Face) || (!img.Whole && s.Home%9 != 4) {
+			continue
+		}
+		positions, err := readVec3(gltfDoc, stickerPrimitive(gltfDoc, s).Attributes["POSITION"])
+		if err != nil {
+			return err
+		}
+		target := stickerUV{sticker: s, uv: make([][2]float64, len(positions))}
+		scale := scales[s.Node]
+		for i, v := range positions {
+			w := rotateVector(rotations[s.Node], vector{v[0] * scale[0], v[1] * scale[1], v[2] * scale[2]})
+			target.uv[i] = [2]float64{dot(w, axes.right), dot(w, axes.down)}
+			for j := range min {
+				min[j], max[j] = math.Min(min[j], target.uv[i][j]), math.Max(max[j], target.uv[i][j])
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	doc.Images = append(doc.Images, &gltf.Image{URI: img.URI})
+	doc.Samplers = append(doc.Samplers, &gltf.Sampler{WrapS: gltf.WrapClampToEdge, WrapT: gltf.WrapClampToEdge})
+	doc.Textures = append(doc.Textures, &gltf.Texture{
+		Sampler: gltf.Index(uint32(len(doc.Samplers) - 1)),
+		Source:  gltf.Index(uint32(len(doc.Images) - 1)),
+	})
+	texture := uint32(len(doc.Textures) - 1)
+
+	// すべてのステッカーの UV を 1 つの埋め込みバッファーにまとめる
+	data := new(bytes.Buffer)
+	buffer := uint32(len(doc.Buffers))
+	for _, target := range targets {
+		offset := uint32(data.Len())
+		for _, uv := range target.uv {
+			_ = binary.Write(data, binary.LittleEndian, [2]float32{
+				float32((uv[0] - min[0]) / (max[0] - min[0])),
+				float32((uv[1] - min[1]) / (max[1] - min[1])),
+			})
+		}
+		doc.BufferViews = append(doc.BufferViews, &gltf.BufferView{
+			Buffer: buffer, ByteOffset: offset, ByteLength: uint32(data.Len()) - offset, Target: gltf.TargetArrayBuffer,
+		})
+		doc.Accessors = append(doc.Accessors, &gltf.Accessor{
+			BufferView:    gltf.Index(uint32(len(doc.BufferViews) - 1)),
+			ComponentType: gltf.ComponentFloat,
+			Count:         uint32(len(target.uv)),
+			Type:          gltf.AccessorVec2,
+		})
+
+		m := copyMaterial(doc.Materials[target.sticker.Material])
+		m.Name = "Image " + faceletName(target.sticker.Home)
+		setMaterialColor(m, colorCodes['w'])
+		m.PBRMetallicRoughness.BaseColorTexture = &gltf.TextureInfo{Index: texture}
+		doc.Materials = append(doc.Materials, m)
+
+		p := stickerPrimitive(doc, target.sticker)
+		p.Attributes["TEXCOORD_0"] = uint32(len(doc.Accessors) - 1)
+		p.Material = gltf.Index(uint32(len(doc.Materials) - 1))
+	}
+
+	b := &gltf.Buffer{ByteLength: uint32(data.Len()), Data: data.Bytes()}
+	b.EmbeddedResource()
+	doc.Buffers = append(doc.Buffers, b)
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/png"
+	"math"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+)
+
+func TestImageDataURI(t *testing.T) {
+	buffer := new(bytes.Buffer)
+	if err := png.Encode(buffer, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	uri, err := imageDataURI(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img := (&gltf.Image{URI: uri}); !img.IsEmbeddedResource() {
+		t.Errorf("uri must be an embedded PNG: %.40s", uri)
+	}
+
+	if _, err := imageDataURI([]byte("GIF89a")); err == nil {
+		t.Error("GIF must be an error")
+	}
+	for _, s := range []string{"file:///etc/passwd", "logo.png", "https://"} {
+		if _, err := imageURL(s); err == nil {
+			t.Errorf("%s must be an error", s)
+		}
+	}
+}
+
+func TestAddStickerImage(t *testing.T) {
+	doc := copyCubeDocument(t)
+	if err := addStickerImage(doc, &stickerImage{URI: "https://example.com/logo.png", Face: FaceF, Whole: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Images) != 1 || len(doc.Textures) != 1 || len(doc.Samplers) != 1 {
+		t.Fatalf("images, textures and samplers must be added: %d, %d, %d", len(doc.Images), len(doc.Textures), len(doc.Samplers))
+	}
+
+	// 展開図の向きで、F0 は左上、F8 は右下の 1/3 の範囲を使う
+	for _, s := range stickers {
+		p := stickerPrimitive(doc, s)
+		accessor, ok := p.Attributes["TEXCOORD_0"]
+		if s.Home/9 != int(FaceF) {
+			if ok {
+				t.Errorf("%s must not have TEXCOORD_0", faceletName(s.Home))
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s must have TEXCOORD_0", faceletName(s.Home))
+			continue
+		}
+		if m := doc.Materials[*p.Material]; m.PBRMetallicRoughness.BaseColorTexture == nil {
+			t.Errorf("material of %s must have the texture", faceletName(s.Home))
+		}
+
+		a := doc.Accessors[accessor]
+		view := doc.BufferViews[*a.BufferView]
+		data := doc.Buffers[view.Buffer].Data[view.ByteOffset:]
+		column, row := float64(s.Home%3), float64(s.Home%9/3)
+		for i := 0; i < int(a.Count); i++ {
+			u := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*8:])))
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*8+4:])))
+			if u < column/3-1e-6 || u > (column+1)/3+1e-6 || v < row/3-1e-6 || v > (row+1)/3+1e-6 {
+				t.Errorf("%s: uv (%g, %g) is out of its cell", faceletName(s.Home), u, v)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	labelsSpeffz = "speffz"
+	// speffzLetters Speffz のコーナー・エッジ共通の文字
+	speffzLetters = "ABCDEFGHIJKLMNOPQRSTUVWX"
+	// labelSchemeLength 1 種類のピースに割り当てる文字数
+	labelSchemeLength = 24
+	// labelHeight ステッカーの幅に対する文字の高さの割合
+	labelHeight = 0.5
+)
+
+// labelColor ステッカーに載せる文字の色
+var labelColor = colorCodes['k']
+
+// labelFaces 目隠し用の文字を割り当てる面の順番 (Speffz と同じ U L F R B D)
+var labelFaces = [6]Face{FaceU, FaceL, FaceF, FaceR, FaceB, FaceD}
+
+// labelCorners・labelEdges 面の左上から時計回りに並べた、コーナー・エッジのステッカーの面内の番号
+var (
+	labelCorners = [4]int{0, 2, 8, 6}
+	labelEdges   = [4]int{1, 5, 7, 3}
+)
+
+// glyphs 文字ごとの線の折れ線。幅 4・高さ 6 の格子の座標で、Y は上向き
+var glyphs = map[byte][][]point{
+	'A': {{{0, 0}, {0, 4}, {2, 6}, {4, 4}, {4, 0}}, {{0, 3}, {4, 3}}},
+	'B': {{{0, 0}, {0, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {0, 3}}, {{3, 3}, {4, 2}, {4, 1}, {3, 0}, {0, 0}}},
+	'C': {{{4, 5}, {3, 6}, {1, 6}, {0, 5}, {0, 1}, {1, 0}, {3, 0}, {4, 1}}},
+	'D': {{{0, 0}, {0, 6}, {2, 6}, {4, 4}, {4, 2}, {2, 0}, {0, 0}}},
+	'E': {{{4, 6}, {0, 6}, {0, 0}, {4, 0}}, {{0, 3}, {3, 3}}},
+	'F': {{{4, 6}, {0, 6}, {0, 0}}, {{0, 3}, {3, 3}}},
+	'G': {{{4, 5}, {3, 6}, {1, 6}, {0, 5}, {0, 1}, {1, 0}, {3, 0}, {4, 1}, {4, 3}, {2, 3}}},
+	'H': {{{0, 0}, {0, 6}}, {{4, 0}, {4, 6}}, {{0, 3}, {4, 3}}},
+	'I': {{{1, 6}, {3, 6}}, {{2, 6}, {2, 0}}, {{1, 0}, {3, 0}}},
+	'J': {{{4, 6}, {4, 1}, {3, 0}, {1, 0}, {0, 1}}},
+	'K': {{{0, 0}, {0, 6}}, {{4, 6}, {0, 2}}, {{1, 3}, {4, 0}}},
+	'L': {{{0, 6}, {0, 0}, {4, 0}}},
+	'M': {{{0, 0}, {0, 6}, {2, 3}, {4, 6}, {4, 0}}},
+	'N': {{{0, 0}, {0, 6}, {4, 0}, {4, 6}}},
+	'O': {{{1, 0}, {0, 1}, {0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 1}, {3, 0}, {1, 0}}},
+	'P': {{{0, 0}, {0, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {0, 3}}},
+	'Q': {{{1, 0}, {0, 1}, {0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 1}, {3, 0}, {1, 0}}, {{2, 2}, {4, 0}}},
+	'R': {{{0, 0}, {0, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {0, 3}}, {{2, 3}, {4, 0}}},
+	'S': {{{4, 5}, {3, 6}, {1, 6}, {0, 5}, {0, 4}, {1, 3}, {3, 3}, {4, 2}, {4, 1}, {3, 0}, {1, 0}, {0, 1}}},
+	'T': {{{0, 6}, {4, 6}}, {{2, 6}, {2, 0}}},
+	'U': {{{0, 6}, {0, 1}, {1, 0}, {3, 0}, {4, 1}, {4, 6}}},
+	'V': {{{0, 6}, {2, 0}, {4, 6}}},
+	'W': {{{0, 6}, {1, 0}, {2, 4}, {3, 0}, {4, 6}}},
+	'X': {{{0, 6}, {4, 0}}, {{0, 0}, {4, 6}}},
+	'Y': {{{0, 6}, {2, 3}, {4, 6}}, {{2, 3}, {2, 0}}},
+	'Z': {{{0, 6}, {4, 6}, {0, 0}, {4, 0}}},
+	'0': {{{1, 0}, {0, 1}, {0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 1}, {3, 0}, {1, 0}}, {{0, 1}, {4, 5}}},
+	'1': {{{1, 5}, {2, 6}, {2, 0}}, {{1, 0}, {3, 0}}},
+	'2': {{{0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 4}, {0, 0}, {4, 0}}},
+	'3': {{{0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {1, 3}}, {{3, 3}, {4, 2}, {4, 1}, {3, 0}, {1, 0}, {0, 1}}},
+	'4': {{{3, 0}, {3, 6}, {0, 2}, {4, 2}}},
+	'5': {{{4, 6}, {0, 6}, {0, 3}, {3, 3}, {4, 2}, {4, 1}, {3, 0}, {1, 0}, {0, 1}}},
+	'6': {{{4, 5}, {3, 6}, {1, 6}, {0, 5}, {0, 1}, {1, 0}, {3, 0}, {4, 1}, {4, 2}, {3, 3}, {0, 3}}},
+	'7': {{{0, 6}, {4, 6}, {1, 0}}},
+	'8': {{{1, 3}, {0, 4}, {0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 4}, {3, 3}, {1, 3}, {0, 2}, {0, 1}, {1, 0}, {3, 0}, {4, 1}, {4, 2}, {3, 3}}},
+	'9': {{{4, 3}, {1, 3}, {0, 4}, {0, 5}, {1, 6}, {3, 6}, {4, 5}, {4, 1}, {3, 0}, {1, 0}, {0, 1}}},
+}
+
+// parseLabels labels パラメーターを、完成状態のファセット番号ごとの文字に変換する。センターには文字を付けない。
+// speffz 以外は U L F R B D の順に各面の左上から時計回りの 24 文字 (コーナーとエッジで共通)、
+// またはコーナーの 24 文字に続けてエッジの 24 文字を指定する。小文字は大文字として扱う
+func parseLabels(s string) (*[faceletCount]byte, error) {
+	letters := strings.ToUpper(s)
+	if s == labelsSpeffz {
+		letters = speffzLetters
+	}
+	if len(letters) != labelSchemeLength && len(letters) != labelSchemeLength*2 {
+		return nil, fmt.Errorf("labels must be %q or %d or %d letters", labelsSpeffz, labelSchemeLength, labelSchemeLength*2)
+	}
+	for i := 0; i < len(letters); i++ {
+		if _, ok := glyphs[letters[i]]; !ok {
+			return nil, fmt.Errorf("unsupported letter of labels: %q", letters[i])
+		}
+	}
+	corners, edges := letters[:labelSchemeLength], letters[len(letters)-labelSchemeLength:]
+
+	var labels [faceletCount]byte
+	for i := 0; i < labelSchemeLength; i++ {
+		face := int(labelFaces[i/4]) * 9
+		labels[face+labelCorners[i%4]] = corners[i]
+		labels[face+labelEdges[i%4]] = edges[i]
+	}
+	return &labels, nil
+}
+
+// addLabels 各ステッカーの少し外側に、完成状態のファセットの文字を平らなメッシュで置く
+func addLabels(doc *gltf.Document, labels *[faceletCount]byte) error {
+	// 格子の 1 目盛りのステッカーの幅に対する長さ
+	unit := labelHeight / 6
+
+	var marks [faceletCount][][]point
+	for facelet, letter := range labels {
+		for _, stroke := range glyphs[letter] {
+			line := make([]point, len(stroke))
+			for i, p := range stroke {
+				line[i] = point{(p.X - 2) * unit, (p.Y - 3) * unit}
+			}
+			marks[facelet] = append(marks[facelet], line)
+		}
+	}
+	return addStickerMarks(doc, "Label", labelColor, &marks, unit*0.8)
+}
+
+// addStickerMarks 各ステッカーの少し外側に、完成状態のファセットごとの折れ線を幅 width の平らなメッシュで置く。
+// 座標はステッカーの中心を原点、ステッカーの幅を 1 とし、展開図の向きで X は右、Y は上。
+// ステッカーと同じノードのメッシュのプリミティブにするため、回転記号や move でステッカーと一緒に動く
+func addStickerMarks(doc *gltf.Document, name string, c color.NRGBA, marks *[faceletCount][][]point, width float64) error {
+	extent, err := solvedExtent()
+	if err != nil {
+		return err
+	}
+	cell := extent / 3
+	lift := extent * 0.002
+
+	base, ok := baseMaterialIndex(doc)
+	if !ok {
+		return nil
+	}
+	m := copyMaterial(doc.Materials[base])
+	m.Name = name
+	setMaterialColor(m, c)
+	doc.Materials = append(doc.Materials, m)
+	material := uint32(len(doc.Materials) - 1)
+
+	type markMesh struct {
+		positions []vector
+		indices   []uint32
+	}
+	meshes := make(map[string]*markMesh)
+
+	rotations := nodeRotations(doc.Nodes)
+	for _, s := range stickers {
+		lines := marks[s.Home]
+		if len(lines) == 0 {
+			continue
+		}
+		axes := faceAxes[s.Home/9]
+		center := rotateVector(rotations[s.Node], s.Center)
+		center = add(center, scaled(axes.normal, stickerDistance-dot(center, axes.normal)+lift))
+
+		mesh, ok := meshes[s.Node]
+		if !ok {
+			mesh = &markMesh{}
+			meshes[s.Node] = mesh
+		}
+		markPoint := func(p point) vector {
+			return add(center, add(scaled(axes.right, p.X*cell), scaled(axes.down, -p.Y*cell)))
+		}
+		for _, line := range lines {
+			for i := 1; i < len(line); i++ {
+				mesh.positions, mesh.indices = appendStroke(mesh.positions, mesh.indices,
+					markPoint(line[i-1]), markPoint(line[i]), axes.normal, width*cell)
+			}
+		}
+	}
+
+	for _, node := range doc.Nodes {
+		mesh, ok := meshes[node.Name]
+		if !ok || node.Mesh == nil {
+			continue
+		}
+		// ステッカーのプリミティブと同じくノードの rotation と scale を適用する前の座標にする
+		r, scale := node.RotationOrDefault(), node.ScaleOrDefault()
+		inverse := [4]float64{-r[0], -r[1], -r[2], r[3]}
+		positions := make([]vector, len(mesh.positions))
+		for i, v := range mesh.positions {
+			v = rotateVector(inverse, v)
+			positions[i] = vector{v[0] / scale[0], v[1] / scale[1], v[2] / scale[2]}
+		}
+		primitives := &doc.Meshes[*node.Mesh].Primitives
+		*primitives = append(*primitives, addPrimitive(doc, positions, mesh.indices, material))
+	}
+	return nil
+}
+
+// appendStroke a から b への幅 width の線を、normal の向きから見て表になる長方形として追加する。
+// 折れ線のつなぎ目が欠けないように両端を width / 2 だけ延ばす
+func appendStroke(positions []vector, indices []uint32, a, b, normal vector, width float64) ([]vector, []uint32) {
+	d := normalize(add(b, scaled(a, -1)))
+	a, b = add(a, scaled(d, -width/2)), add(b, scaled(d, width/2))
+	side := scaled(normalize(cross(normal, d)), width/2)
+
+	k := uint32(len(positions))
+	positions = append(positions, add(a, scaled(side, -1)), add(b, scaled(side, -1)), add(b, side), add(a, side))
+	indices = append(indices, k, k+1, k+2, k, k+2, k+3)
+	return positions, indices
+}
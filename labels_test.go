@@ -0,0 +1,90 @@
+package main
+
+import "testing"
+
+func TestParseLabels(t *testing.T) {
+	labels, err := parseLabels(labelsSpeffz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]byte{
+		0: 'A', 1: 'A', 2: 'B', 4: 0, 5: 'B', 8: 'C', // U
+		9: 'M', 10: 'M', // R
+		18: 'I', 23: 'J', // F
+		27: 'U', 28: 'U', // D
+		36: 'E', 39: 'H', // L
+		45: 'Q', 51: 'T', // B
+	}
+	for facelet, w := range want {
+		if labels[facelet] != w {
+			t.Errorf("label of %s must be %q, actual: %q", faceletName(facelet), w, labels[facelet])
+		}
+	}
+
+	labels, err = parseLabels("abcdefghijklmnopqrstuvwx012345678901234567890123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels[0] != 'A' || labels[1] != '0' || labels[36] != 'E' || labels[39] != '7' {
+		t.Errorf("custom labels are wrong: %q", labels[:])
+	}
+
+	for _, s := range []string{"SPEFFZ", "ABCDEFGHIJKLMNOPQRSTUVW", "ABCDEFGHIJKLMNOPQRSTUVW!"} {
+		if _, err := parseLabels(s); err == nil {
+			t.Errorf("%s must be an error", s)
+		}
+	}
+}
+
+func TestAddLabels(t *testing.T) {
+	doc := copyCubeDocument(t)
+	labels, err := parseLabels(labelsSpeffz)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := addLabels(doc, labels); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, node := range doc.Nodes {
+		before, after := len(gltfDoc.Meshes[*node.Mesh].Primitives), len(doc.Meshes[*node.Mesh].Primitives)
+		if pieceKind(mustNodeHome(t, node.Name)) == centerPiece {
+			if after != before {
+				t.Errorf("center %s must not have labels", node.Name)
+			}
+			continue
+		}
+		if after != before+1 {
+			t.Errorf("%s must have a label primitive", node.Name)
+			continue
+		}
+
+		// 文字はノードの変換を適用すると、すべてステッカーより外側にある
+		label := doc.Meshes[*node.Mesh].Primitives[after-1]
+		if doc.Materials[*label.Material].Name != "Label" {
+			t.Errorf("%s: material of label = %s", node.Name, doc.Materials[*label.Material].Name)
+		}
+		positions, err := readVec3(doc, label.Attributes["POSITION"])
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, r := gltfDoc.Nodes[i].ScaleOrDefault(), gltfDoc.Nodes[i].RotationOrDefault()
+		for _, v := range positions {
+			w := rotateVector(r, vector{v[0] * s[0], v[1] * s[1], v[2] * s[2]})
+			if d, _ := outerFace(w); d <= stickerDistance {
+				t.Errorf("%s: label %v must be outside of stickers", node.Name, w)
+				break
+			}
+		}
+	}
+}
+
+func mustNodeHome(t *testing.T, name string) vector {
+	t.Helper()
+	home, err := nodeHome(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return home
+}
@@ -35,9 +35,15 @@ func main() {
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(10 * time.Second))
 	r.Use(cors.AllowAll().Handler)
-	r.Use(middleware.Compress(5, ContentTypeGltf))
+	r.Use(middleware.Compress(5, ContentTypeGltf, ContentTypeSVG))
 
 	r.Get("/cube.gltf", getCubeHandler)
+	r.Post("/cube.gltf", postCubeHandler)
+	r.Post("/sheet", postSheetHandler)
+	r.Get("/describe", getDescribeHandler)
+	r.Get("/visualcube.svg", getVisualCubeHandler(imageFormatSVG))
+	r.Get("/visualcube.png", getVisualCubeHandler(imageFormatPNG))
+	r.Get("/visualcube.php", getVisualCubeHandler(""))
 
 	fmt.Println("listening...")
 	if err := http.ListenAndServe(":"+port, r); err != nil {
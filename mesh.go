@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+// worldPrimitive ノードの変換を適用した、ワールド座標系のプリミティブ
+type worldPrimitive struct {
+	Node      string
+	Primitive int
+	Material  uint32
+	Positions []vector
+	Normals   []vector
+	Indices   []uint32
+}
+
+// worldPrimitives メッシュの頂点にノードの scale・rotation・translation を適用する。
+// move はノードの Rotation を変えるだけなので、メッシュのデータは doc のものをそのまま使う
+func worldPrimitives(doc *gltf.Document, nodes []*gltf.Node) ([]worldPrimitive, error) {
+	var primitives []worldPrimitive
+	for _, node := range nodes {
+		local, err := meshPrimitives(doc, node)
+		if err != nil {
+			return nil, err
+		}
+
+		s, r, t := node.ScaleOrDefault(), node.RotationOrDefault(), node.TranslationOrDefault()
+		for _, p := range local {
+			primitives = append(primitives, p.transformed(s, r, t))
+		}
+	}
+
+	return primitives, nil
+}
+
+// meshPrimitives ノードのメッシュの三角形のプリミティブを、ノードの変換を適用せずに読み取る
+func meshPrimitives(doc *gltf.Document, node *gltf.Node) ([]worldPrimitive, error) {
+	if node.Mesh == nil {
+		return nil, nil
+	}
+
+	var primitives []worldPrimitive
+	for i, p := range doc.Meshes[*node.Mesh].Primitives {
+		if p.Mode != gltf.PrimitiveTriangles || p.Indices == nil || p.Material == nil {
+			continue
+		}
+
+		positions, err := readVec3(doc, p.Attributes["POSITION"])
+		if err != nil {
+			return nil, err
+		}
+		normals, err := readVec3(doc, p.Attributes["NORMAL"])
+		if err != nil {
+			return nil, err
+		}
+		indices, err := readIndices(doc, *p.Indices)
+		if err != nil {
+			return nil, err
+		}
+
+		primitives = append(primitives, worldPrimitive{
+			Node:      node.Name,
+			Primitive: i,
+			Material:  *p.Material,
+			Positions: positions,
+			Normals:   normals,
+			Indices:   indices,
+		})
+	}
+
+	return primitives, nil
+}
+
+// transformed scale・rotation・translation を適用したプリミティブを取得する。法線には scale の逆数を使う
+func (p worldPrimitive) transformed(s [3]float64, r [4]float64, t [3]float64) worldPrimitive {
+	positions := make([]vector, len(p.Positions))
+	for i, v := range p.Positions {
+		positions[i] = add(rotateVector(r, vector{v[0] * s[0], v[1] * s[1], v[2] * s[2]}), t)
+	}
+	normals := make([]vector, len(p.Normals))
+	for i, n := range p.Normals {
+		normals[i] = normalize(rotateVector(r, vector{n[0] / s[0], n[1] / s[1], n[2] / s[2]}))
+	}
+
+	p.Positions, p.Normals = positions, normals
+	return p
+}
+
+// bounds プリミティブ全体を囲む直方体の最小・最大の座標を取得する
+func bounds(primitives []worldPrimitive) (vector, vector) {
+	min, max := vector{math.Inf(1), math.Inf(1), math.Inf(1)}, vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range primitives {
+		for _, v := range p.Positions {
+			for i := range v {
+				min[i], max[i] = math.Min(min[i], v[i]), math.Max(max[i], v[i])
+			}
+		}
+	}
+	return min, max
+}
+
+// solvedExtent 完成状態の cube.gltf を囲む立方体の 1 辺の長さを求める
+func solvedExtent() (float64, error) {
+	primitives, err := worldPrimitives(gltfDoc, gltfDoc.Nodes)
+	if err != nil {
+		return 0, err
+	}
+	min, max := bounds(primitives)
+	return math.Max(max[0]-min[0], math.Max(max[1]-min[1], max[2]-min[2])), nil
+}
+
+// normalize ベクトルを長さ 1 にする
+func normalize(v vector) vector {
+	length := math.Sqrt(dot(v, v))
+	if length == 0 {
+		return v
+	}
+	return scaled(v, 1/length)
+}
+
+// addMesh 三角形のメッシュを新しい埋め込みバッファーに書き込んで追加し、メッシュのインデックスを取得する
+func addMesh(doc *gltf.Document, name string, positions []vector, indices []uint32, material uint32) uint32 {
+	doc.Meshes = append(doc.Meshes, &gltf.Mesh{
+		Name:       name,
+		Primitives: []*gltf.Primitive{addPrimitive(doc, positions, indices, material)},
+	})
+	return uint32(len(doc.Meshes) - 1)
+}
+
+// addPrimitive 三角形の頂点とインデックスを新しい埋め込みバッファーに書き込み、それを参照するプリミティブを作る
+func addPrimitive(doc *gltf.Document, positions []vector, indices []uint32, material uint32) *gltf.Primitive {
+	data := new(bytes.Buffer)
+	min, max := vector{math.Inf(1), math.Inf(1), math.Inf(1)}, vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, v := range positions {
+		_ = binary.Write(data, binary.LittleEndian, [3]float32{float32(v[0]), float32(v[1]), float32(v[2])})
+		for i := range v {
+			min[i], max[i] = math.Min(min[i], v[i]), math.Max(max[i], v[i])
+		}
+	}
+	positionsLength := uint32(data.Len())
+	_ = binary.Write(data, binary.LittleEndian, indices)
+
+	buffer := &gltf.Buffer{ByteLength: uint32(data.Len()), Data: data.Bytes()}
+	buffer.EmbeddedResource()
+	doc.Buffers = append(doc.Buffers, buffer)
+	bufferIndex := uint32(len(doc.Buffers) - 1)
+
+	doc.BufferViews = append(doc.BufferViews,
+		&gltf.BufferView{Buffer: bufferIndex, ByteLength: positionsLength, Target: gltf.TargetArrayBuffer},
+		&gltf.BufferView{
+			Buffer: bufferIndex, ByteOffset: positionsLength, ByteLength: uint32(data.Len()) - positionsLength,
+			Target: gltf.TargetElementArrayBuffer,
+		},
+	)
+	positionsView, indicesView := uint32(len(doc.BufferViews)-2), uint32(len(doc.BufferViews)-1)
+
+	doc.Accessors = append(doc.Accessors,
+		&gltf.Accessor{
+			BufferView:    gltf.Index(positionsView),
+			ComponentType: gltf.ComponentFloat,
+			Count:         uint32(len(positions)),
+			Type:          gltf.AccessorVec3,
+			Min:           min[:],
+			Max:           max[:],
+		},
+		&gltf.Accessor{
+			BufferView:    gltf.Index(indicesView),
+			ComponentType: gltf.ComponentUint,
+			Count:         uint32(len(indices)),
+			Type:          gltf.AccessorScalar,
+		},
+	)
+	positionsAccessor, indicesAccessor := uint32(len(doc.Accessors)-2), uint32(len(doc.Accessors)-1)
+
+	return &gltf.Primitive{
+		Attributes: gltf.Attribute{"POSITION": positionsAccessor},
+		Indices:    gltf.Index(indicesAccessor),
+		Material:   gltf.Index(material),
+	}
+}
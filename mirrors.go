@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	defaultMirrorDistance = 1.0
+	maxMirrorDistance     = 10.0
+)
+
+// mirrorFaces 既定の視点 (U F R 側) から見えない、ステッカーを映す面
+var mirrorFaces = []Face{FaceD, FaceB, FaceL}
+
+// addMirrors 見えない面にあるステッカーを、その面から distance (キューブの 1 辺に対する割合) だけ離れた位置に映す。
+// 面ごとに、面と平行な平面で反転する親ノード (Mirror D など) を作り、その子にピースのノードの変換をそのまま使う
+// ステッカーだけのノードを置く。ステッカーは反転してキューブの方を向く。hidden のピースのステッカーは映さない
+func addMirrors(doc *gltf.Document, distance float64, hidden map[string]bool) error {
+	state, err := stateOf(doc.Nodes)
+	if err != nil {
+		return err
+	}
+	extent, err := solvedExtent()
+	if err != nil {
+		return err
+	}
+
+	nodes := make(map[string]*gltf.Node, len(doc.Nodes))
+	for _, node := range doc.Nodes {
+		nodes[node.Name] = node
+	}
+
+	scene := uint32(0)
+	if doc.Scene != nil {
+		scene = *doc.Scene
+	}
+
+	for _, face := range mirrorFaces {
+		normal := faceAxes[face].normal
+		// 面から distance / 2 の平面で反転すると、ステッカーは面から distance だけ離れる
+		plane := extent/2 + distance*extent/2
+		parent := &gltf.Node{Name: fmt.Sprintf("Mirror %s", faceNames[face]), Scale: [3]float64{1, 1, 1}}
+		for i, v := range normal {
+			parent.Translation[i] = 2 * plane * v
+			if v != 0 {
+				parent.Scale[i] = -1
+			}
+		}
+
+		for facelet := int(face) * 9; facelet < int(face+1)*9; facelet++ {
+			sticker := stickers[state[facelet]]
+			if hidden[sticker.Node] {
+				continue
+			}
+			node, ok := nodes[sticker.Node]
+			if !ok || node.Mesh == nil {
+				return fmt.Errorf("node not found: %s", sticker.Node)
+			}
+
+			primitive := *doc.Meshes[*node.Mesh].Primitives[sticker.Primitive]
+			name := fmt.Sprintf("%s %s", sticker.Node, faceletName(facelet))
+			doc.Meshes = append(doc.Meshes, &gltf.Mesh{Name: name, Primitives: []*gltf.Primitive{&primitive}})
+
+			doc.Nodes = append(doc.Nodes, &gltf.Node{
+				Name:        name,
+				Mesh:        gltf.Index(uint32(len(doc.Meshes) - 1)),
+				Rotation:    node.Rotation,
+				Scale:       node.Scale,
+				Translation: node.Translation,
+			})
+			parent.Children = append(parent.Children, uint32(len(doc.Nodes)-1))
+		}
+
+		doc.Nodes = append(doc.Nodes, parent)
+		doc.Scenes[scene].Nodes = append(doc.Scenes[scene].Nodes, uint32(len(doc.Nodes)-1))
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+)
+
+func TestAddMirrors(t *testing.T) {
+	doc := copyCubeDocument(t)
+	extent, err := solvedExtent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes, err := applyAlg(doc.Nodes, strings.Split("R U R' U'", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Nodes = nodes
+	if err := addMirrors(doc, 0.5, nil); err != nil {
+		t.Fatal(err)
+	}
+	state, err := stateOf(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scene := doc.Scenes[*doc.Scene].Nodes
+	for i, face := range mirrorFaces {
+		parent := doc.Nodes[scene[len(scene)-len(mirrorFaces)+i]]
+		if parent.Name != "Mirror "+faceNames[face] || len(parent.Children) != 9 {
+			t.Fatalf("unexpected mirror node: %s with %d children", parent.Name, len(parent.Children))
+		}
+
+		normal := faceAxes[face].normal
+		for j, child := range parent.Children {
+			primitives, err := worldPrimitives(doc, []*gltf.Node{doc.Nodes[child]})
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := primitives[0].transformed(parent.Scale, [4]float64{0, 0, 0, 1}, parent.Translation)
+			if p.Material != stickers[state[int(face)*9+j]].Material {
+				t.Errorf("%s: material must be the sticker's", doc.Nodes[child].Name)
+			}
+
+			// 映したステッカーは面から 0.5 辺だけ外側にあり、キューブの方を向く
+			center, facing := vector{}, 0.0
+			for _, v := range p.Positions {
+				center = add(center, scaled(v, 1/float64(len(p.Positions))))
+			}
+			for _, n := range p.Normals {
+				facing += dot(n, normal)
+			}
+			if d := dot(center, normal); d < extent*0.95 || d > extent*1.05 {
+				t.Errorf("%s: distance from the center must be about %g, actual: %g", doc.Nodes[child].Name, extent, d)
+			}
+			if facing >= 0 {
+				t.Errorf("%s: mirrored sticker must face the cube", doc.Nodes[child].Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image/color"
+)
+
+const (
+	netStickerSize = 30
+	netStickerGap  = 2
+	netFaceGap     = 6
+	netFaceSize    = netStickerSize*3 + netStickerGap*4
+)
+
+// netOffsets 展開図上の各面の位置 (面の個数単位)
+var netOffsets = [6][2]int{
+	FaceU: {1, 0},
+	FaceR: {2, 1},
+	FaceF: {1, 1},
+	FaceD: {1, 2},
+	FaceL: {0, 1},
+	FaceB: {3, 1},
+}
+
+// generateNetSVG 回転記号を適用したキューブの展開図を SVG で生成する
+func generateNetSVG(algorithm []string) ([]byte, error) {
+	nodes, err := applyAlg(gltfDoc.Nodes, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := stateOf(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var colors [faceletCount]color.NRGBA
+	for i, s := range state {
+		colors[i] = materialColor(gltfDoc, stickers[s].Material)
+	}
+
+	width := netFaceSize*4 + netFaceGap*5
+	height := netFaceSize*3 + netFaceGap*4
+	c := newCanvas(imageFormatSVG, width, height, viewBox{0, 0, float64(width), float64(height)}, colorTransparent)
+	drawNet(c, colors, baseMaterialColor(gltfDoc))
+
+	return c.encode()
+}
+
+// drawNet 各ファセットの色で展開図を描画する
+func drawNet(c canvas, colors [faceletCount]color.NRGBA, body color.NRGBA) {
+	for f, offset := range netOffsets {
+		x := float64(netFaceGap + offset[0]*(netFaceSize+netFaceGap))
+		y := float64(netFaceGap + offset[1]*(netFaceSize+netFaceGap))
+		c.polygon(rect(x, y, netFaceSize, netFaceSize), body)
+
+		for i := 0; i < 9; i++ {
+			c.polygon(rect(
+				x+float64(netStickerGap+(i%3)*(netStickerSize+netStickerGap)),
+				y+float64(netStickerGap+(i/3)*(netStickerSize+netStickerGap)),
+				netStickerSize, netStickerSize,
+			), colors[f*9+i])
+		}
+	}
+}
+
+// rect 長方形の頂点を取得する
+func rect(x, y, width, height float64) []point {
+	return []point{{x, y}, {x + width, y}, {x + width, y + height}, {x, y + height}}
+}
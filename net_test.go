@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var svgFillRegex = regexp.MustCompile(`fill="(#[0-9a-f]{6})"`)
+
+func TestGenerateNetSVG(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	scheme := defaultScheme()
+
+	tests := []struct {
+		alg []string
+		// faces 確かめるファセットと、そのファセットに来る完成状態の面の色
+		faces map[int]Face
+	}{
+		{alg: nil, faces: map[int]Face{2: FaceU, 22: FaceF, 29: FaceD, 45: FaceB, 13: FaceR}},
+		{
+			alg: []string{"R"},
+			faces: map[int]Face{
+				0: FaceU, 2: FaceF, 5: FaceF, 8: FaceF, // U の右列に F
+				20: FaceD, 23: FaceD, 26: FaceD, // F の右列に D
+				29: FaceB, 32: FaceB, 35: FaceB, // D の右列に B
+				45: FaceU, 48: FaceU, 51: FaceU, // B の左列に U
+				9: FaceR, 13: FaceR, 17: FaceR,
+			},
+		},
+	}
+	for _, tt := range tests {
+		data, err := generateNetSVG(tt.alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 面ごとに本体の正方形と 9 個のステッカーを描く
+		fills := svgFillRegex.FindAllStringSubmatch(string(data), -1)
+		if len(fills) != 6*10 {
+			t.Fatalf("%v: net must have %d polygons, actual: %d", tt.alg, 6*10, len(fills))
+		}
+		for facelet, face := range tt.faces {
+			fill := fills[facelet/9*10+1+facelet%9][1]
+			if want := hexColor(scheme[face]); fill != want {
+				t.Errorf("%v: %s must be %s, actual: %s", tt.alg, faceletName(facelet), want, fill)
+			}
+		}
+	}
+}
+
+func TestGetCubeHandlerNetSVG(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{query: "format=svg&alg=R+U", status: http.StatusOK},
+		{query: "format=svg&alg=R3", status: http.StatusBadRequest},
+		{query: "format=svg&alg=R+x", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		getCubeHandler(w, httptest.NewRequest(http.MethodGet, "/cube.gltf?"+tt.query, nil))
+		if w.Code != tt.status {
+			t.Errorf("%s: status must be %d, actual: %d %s", tt.query, tt.status, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	objFileName = "cube.obj"
+	mtlFileName = "cube.mtl"
+)
+
+// generateOBJ 回転記号を適用したキューブを OBJ と MTL にして zip にまとめる
+func generateOBJ(algorithm []string) ([]byte, error) {
+	nodes, err := applyAlg(gltfDoc.Nodes, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	primitives, err := worldPrimitives(gltfDoc, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return createZip([]zipFile{
+		{Name: objFileName, Data: encodeOBJ(gltfDoc, primitives)},
+		{Name: mtlFileName, Data: encodeMTL(gltfDoc)},
+	})
+}
+
+// zipFile zip にまとめるファイル
+type zipFile struct {
+	Name string
+	Data []byte
+}
+
+// createZip ファイルを圧縮して zip にまとめる
+func createZip(files []zipFile) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	w := zip.NewWriter(buffer)
+
+	for _, f := range files {
+		fw, err := w.CreateHeader(&zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// encodeOBJ プリミティブをノードごとのオブジェクトとして OBJ 形式に変換する
+func encodeOBJ(doc *gltf.Document, primitives []worldPrimitive) []byte {
+	buffer := new(bytes.Buffer)
+	fmt.Fprintf(buffer, "mtllib %s\n", mtlFileName)
+
+	offset := uint32(1)
+	node := ""
+	for _, p := range primitives {
+		if p.Node != node {
+			node = p.Node
+			fmt.Fprintf(buffer, "o %s\n", node)
+		}
+
+		for _, v := range p.Positions {
+			fmt.Fprintf(buffer, "v %.6f %.6f %.6f\n", v[0], v[1], v[2])
+		}
+		for _, n := range p.Normals {
+			fmt.Fprintf(buffer, "vn %.6f %.6f %.6f\n", n[0], n[1], n[2])
+		}
+
+		fmt.Fprintf(buffer, "usemtl %s\n", materialName(doc, p.Material))
+		for i := 0; i+2 < len(p.Indices); i += 3 {
+			a, b, c := p.Indices[i]+offset, p.Indices[i+1]+offset, p.Indices[i+2]+offset
+			fmt.Fprintf(buffer, "f %d//%d %d//%d %d//%d\n", a, a, b, b, c, c)
+		}
+		offset += uint32(len(p.Positions))
+	}
+
+	return buffer.Bytes()
+}
+
+// encodeMTL マテリアルの baseColorFactor を MTL 形式に変換する。unlit のため照明は使わない (illum 0)
+func encodeMTL(doc *gltf.Document) []byte {
+	buffer := new(bytes.Buffer)
+	for i, m := range doc.Materials {
+		c := *gltf.NewRGBA()
+		if m.PBRMetallicRoughness != nil {
+			c = m.PBRMetallicRoughness.BaseColorFactorOrDefault()
+		}
+
+		fmt.Fprintf(buffer, "newmtl %s\n", materialName(doc, uint32(i)))
+		fmt.Fprintf(buffer, "Kd %.6f %.6f %.6f\n", c.R, c.G, c.B)
+		fmt.Fprintf(buffer, "d %.6f\n", c.A)
+		fmt.Fprintf(buffer, "illum 0\n\n")
+	}
+
+	return buffer.Bytes()
+}
+
+// materialName OBJ / MTL で使うマテリアル名を取得する
+func materialName(doc *gltf.Document, material uint32) string {
+	if name := strings.Join(strings.Fields(doc.Materials[material].Name), "_"); name != "" {
+		return name
+	}
+	return fmt.Sprintf("Material_%d", material)
+}
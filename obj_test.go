@@ -0,0 +1,101 @@
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestGenerateOBJ(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	alg := []string{"R", "U", "R'", "U'"}
+	data, err := generateOBJ(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name] = b
+	}
+	if len(files) != 2 || files[objFileName] == nil || files[mtlFileName] == nil {
+		t.Fatalf("zip must have %s and %s, actual: %d files", objFileName, mtlFileName, len(r.File))
+	}
+
+	nodes, err := applyAlg(gltfDoc.Nodes, alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primitives, err := worldPrimitives(gltfDoc, nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertices, faces := 0, 0
+	for _, p := range primitives {
+		vertices += len(p.Positions)
+		faces += len(p.Indices) / 3
+	}
+
+	counts := make(map[string]int)
+	used := make(map[string]bool)
+	s := bufio.NewScanner(bytes.NewReader(files[objFileName]))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		counts[fields[0]]++
+		if fields[0] == "usemtl" {
+			used[fields[1]] = true
+		}
+	}
+	if counts["v"] != vertices || counts["vn"] != vertices {
+		t.Errorf("vertex count must be %d, actual: v %d, vn %d", vertices, counts["v"], counts["vn"])
+	}
+	if counts["f"] != faces {
+		t.Errorf("face count must be %d, actual: %d", faces, counts["f"])
+	}
+	if counts["o"] != len(nodes) {
+		t.Errorf("object count must be %d, actual: %d", len(nodes), counts["o"])
+	}
+
+	// MTL には glTF のマテリアルが順に並び、OBJ で使うマテリアルはすべて定義されている
+	var defined []string
+	s = bufio.NewScanner(bytes.NewReader(files[mtlFileName]))
+	for s.Scan() {
+		if fields := strings.Fields(s.Text()); len(fields) == 2 && fields[0] == "newmtl" {
+			defined = append(defined, fields[1])
+		}
+	}
+	if len(defined) != len(gltfDoc.Materials) {
+		t.Fatalf("material count must be %d, actual: %d", len(gltfDoc.Materials), len(defined))
+	}
+	for i, name := range defined {
+		if want := materialName(gltfDoc, uint32(i)); name != want {
+			t.Errorf("material %d must be %s, actual: %s", i, want, name)
+		}
+		delete(used, name)
+	}
+	for name := range used {
+		t.Errorf("material %s is used but not defined", name)
+	}
+}
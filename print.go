@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+const (
+	// defaultCubeSize 実物のキューブの 1 辺の長さ (mm)
+	defaultCubeSize = 57.0
+	maxCubeSize     = 1000.0
+	// stickerThickness STL で本体から切り出すステッカーの厚さ (mm)
+	stickerThickness = 0.6
+)
+
+type triangle [3]vector
+
+// printPiece 印刷用のピース。本体と外側のステッカーを合わせると閉じたメッシュになる
+type printPiece struct {
+	Name     string
+	Body     []triangle // 本体と、内側を向いたステッカー
+	Stickers []printSticker
+}
+
+type printSticker struct {
+	Material  uint32
+	Triangles []triangle
+}
+
+// printPieces 回転記号を適用したピースを、1 辺が size mm・Z 軸が上の座標系で取得する。
+// cube.gltf のノードには約 100 倍の scale があるため、キューブ全体の大きさから縮尺を決める
+func printPieces(algorithm []string, size float64) ([]printPiece, error) {
+	nodes, err := applyAlg(gltfDoc.Nodes, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	primitives, err := worldPrimitives(gltfDoc, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	min, max := bounds(primitives)
+	k := size / math.Max(max[0]-min[0], math.Max(max[1]-min[1], max[2]-min[2]))
+
+	// glTF (Y 軸が上) から Z 軸が上の座標系に回転し、XY の中心を原点に、底面を Z = 0 に合わせる
+	toPrint := func(v vector) vector {
+		return vector{
+			(v[0] - (min[0]+max[0])/2) * k,
+			-(v[2] - (min[2]+max[2])/2) * k,
+			(v[1] - min[1]) * k,
+		}
+	}
+
+	outer := make(map[string]bool, len(stickers))
+	for _, s := range stickers {
+		outer[fmt.Sprintf("%s/%d", s.Node, s.Primitive)] = true
+	}
+
+	var pieces []printPiece
+	for _, p := range primitives {
+		if len(pieces) == 0 || pieces[len(pieces)-1].Name != p.Node {
+			pieces = append(pieces, printPiece{Name: p.Node})
+		}
+		piece := &pieces[len(pieces)-1]
+
+		triangles := make([]triangle, 0, len(p.Indices)/3)
+		for i := 0; i+2 < len(p.Indices); i += 3 {
+			triangles = append(triangles, triangle{
+				toPrint(p.Positions[p.Indices[i]]),
+				toPrint(p.Positions[p.Indices[i+1]]),
+				toPrint(p.Positions[p.Indices[i+2]]),
+			})
+		}
+
+		if outer[fmt.Sprintf("%s/%d", p.Node, p.Primitive)] {
+			piece.Stickers = append(piece.Stickers, printSticker{Material: p.Material, Triangles: triangles})
+		} else {
+			piece.Body = append(piece.Body, triangles...)
+		}
+	}
+
+	return pieces, nil
+}
+
+// generate3MF ピースごとの閉じたメッシュと三角形ごとの色を 3MF にまとめる
+func generate3MF(algorithm []string, size float64) ([]byte, error) {
+	pieces, err := printPieces(algorithm, size)
+	if err != nil {
+		return nil, err
+	}
+
+	body, ok := baseMaterialIndex(gltfDoc)
+	if !ok {
+		return nil, fmt.Errorf("material not found: BaseColor")
+	}
+
+	const materialsID = 1
+
+	model := new(bytes.Buffer)
+	model.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	model.WriteString(`<model unit="millimeter" xml:lang="en-US" xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02">`)
+	model.WriteString("<resources>")
+	fmt.Fprintf(model, `<basematerials id="%d">`, materialsID)
+	for i := range gltfDoc.Materials {
+		c := materialColor(gltfDoc, uint32(i))
+		fmt.Fprintf(model, `<base name="%s" displaycolor="#%02X%02X%02X%02X"/>`, materialName(gltfDoc, uint32(i)), c.R, c.G, c.B, c.A)
+	}
+	model.WriteString("</basematerials>")
+
+	for i, piece := range pieces {
+		fmt.Fprintf(model, `<object id="%d" type="model" name="%s" pid="%d" pindex="%d"><mesh>`, i+materialsID+1, piece.Name, materialsID, body)
+
+		var (
+			vertices  []vector
+			indices   = make(map[[3]int64]int)
+			triangles []string
+		)
+		index := func(v vector) int {
+			key := [3]int64{int64(math.Round(v[0] * 1e4)), int64(math.Round(v[1] * 1e4)), int64(math.Round(v[2] * 1e4))}
+			if i, ok := indices[key]; ok {
+				return i
+			}
+			indices[key] = len(vertices)
+			vertices = append(vertices, v)
+			return len(vertices) - 1
+		}
+		addTriangles := func(ts []triangle, material uint32) {
+			for _, t := range ts {
+				triangles = append(triangles, fmt.Sprintf(`<triangle v1="%d" v2="%d" v3="%d" pid="%d" p1="%d"/>`,
+					index(t[0]), index(t[1]), index(t[2]), materialsID, material))
+			}
+		}
+
+		addTriangles(piece.Body, body)
+		for _, s := range piece.Stickers {
+			addTriangles(s.Triangles, s.Material)
+		}
+
+		model.WriteString("<vertices>")
+		for _, v := range vertices {
+			fmt.Fprintf(model, `<vertex x="%.4f" y="%.4f" z="%.4f"/>`, v[0], v[1], v[2])
+		}
+		model.WriteString("</vertices><triangles>")
+		for _, t := range triangles {
+			model.WriteString(t)
+		}
+		model.WriteString("</triangles></mesh></object>")
+	}
+	model.WriteString("</resources><build>")
+	for i := range pieces {
+		fmt.Fprintf(model, `<item objectid="%d"/>`, i+materialsID+1)
+	}
+	model.WriteString("</build></model>")
+
+	return createZip([]zipFile{
+		{Name: "[Content_Types].xml", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>` +
+			`</Types>`)},
+		{Name: "_rels/.rels", Data: []byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Target="/3D/3dmodel.model" Id="rel0" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>` +
+			`</Relationships>`)},
+		{Name: "3D/3dmodel.model", Data: model.Bytes()},
+	})
+}
+
+// generateSTL 色ごとの STL を zip にまとめる。マルチマテリアルで印刷できるように、
+// ステッカーは厚さ stickerThickness の板として本体から切り出す
+func generateSTL(algorithm []string, size float64) ([]byte, error) {
+	pieces, err := printPieces(algorithm, size)
+	if err != nil {
+		return nil, err
+	}
+
+	body, ok := baseMaterialIndex(gltfDoc)
+	if !ok {
+		return nil, fmt.Errorf("material not found: BaseColor")
+	}
+
+	solids := make(map[uint32][]triangle)
+	for _, piece := range pieces {
+		solids[body] = append(solids[body], piece.Body...)
+		for _, s := range piece.Stickers {
+			tile, pocket := extrudeSticker(s.Triangles, stickerThickness)
+			solids[s.Material] = append(solids[s.Material], tile...)
+			solids[body] = append(solids[body], pocket...)
+		}
+	}
+
+	var files []zipFile
+	for i := range gltfDoc.Materials {
+		if triangles, ok := solids[uint32(i)]; ok {
+			name := materialName(gltfDoc, uint32(i))
+			files = append(files, zipFile{Name: name + ".stl", Data: encodeSTL(name, triangles)})
+		}
+	}
+
+	return createZip(files)
+}
+
+// extrudeSticker 平面のステッカーを内側に厚さ thickness だけ押し出した板と、
+// 本体側のくぼみ (底面と側面) を取得する
+func extrudeSticker(triangles []triangle, thickness float64) ([]triangle, []triangle) {
+	if len(triangles) == 0 {
+		return nil, nil
+	}
+
+	t := triangles[0]
+	normal := normalize(cross(add(t[1], scaled(t[0], -1)), add(t[2], scaled(t[0], -1))))
+	offset := scaled(normal, -thickness)
+
+	// 向きのある辺のうち、逆向きの辺が無いものがステッカーの外周になる
+	type edge [2]vector
+	edges := make(map[edge]bool)
+	for _, t := range triangles {
+		for i := range t {
+			edges[edge{t[i], t[(i+1)%3]}] = true
+		}
+	}
+
+	var tile, pocket []triangle
+	for _, t := range triangles {
+		inner := triangle{add(t[0], offset), add(t[1], offset), add(t[2], offset)}
+		tile = append(tile, t, triangle{inner[0], inner[2], inner[1]})
+		pocket = append(pocket, inner)
+
+		for i := range t {
+			a, b := t[i], t[(i+1)%3]
+			if edges[edge{b, a}] {
+				continue
+			}
+			ia, ib := add(a, offset), add(b, offset)
+			tile = append(tile, triangle{a, ia, ib}, triangle{a, ib, b})
+			pocket = append(pocket, triangle{a, ib, ia}, triangle{a, b, ib})
+		}
+	}
+
+	return tile, pocket
+}
+
+// encodeSTL 三角形をバイナリ形式の STL に変換する
+func encodeSTL(name string, triangles []triangle) []byte {
+	buffer := new(bytes.Buffer)
+
+	var header [80]byte
+	copy(header[:], name)
+	buffer.Write(header[:])
+	_ = binary.Write(buffer, binary.LittleEndian, uint32(len(triangles)))
+
+	for _, t := range triangles {
+		normal := normalize(cross(add(t[1], scaled(t[0], -1)), add(t[2], scaled(t[0], -1))))
+		var data [12]float32
+		for i := 0; i < 3; i++ {
+			data[i] = float32(normal[i])
+			for j := 0; j < 3; j++ {
+				data[3+j*3+i] = float32(t[j][i])
+			}
+		}
+		_ = binary.Write(buffer, binary.LittleEndian, data)
+		_ = binary.Write(buffer, binary.LittleEndian, uint16(0))
+	}
+
+	return buffer.Bytes()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"net/url"
+	"testing"
+)
+
+func TestExtrudeSticker(t *testing.T) {
+	square := []triangle{
+		{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}},
+		{{0, 0, 0}, {1, 1, 0}, {0, 1, 0}},
+	}
+
+	tile, pocket := extrudeSticker(square, 0.5)
+
+	// 板は閉じていて、すべての辺が逆向きの辺と対になる
+	edges := make(map[[2]vector]int)
+	for _, tr := range tile {
+		for i := range tr {
+			edges[[2]vector{tr[i], tr[(i+1)%3]}]++
+		}
+	}
+	for e, n := range edges {
+		if n != 1 || edges[[2]vector{e[1], e[0]}] != 1 {
+			t.Fatalf("tile is not closed at edge %v", e)
+		}
+	}
+
+	// くぼみは板の表面以外を裏返したものになる
+	if len(pocket) != len(tile)-len(square) {
+		t.Errorf("pocket must have %d triangles, actual: %d", len(tile)-len(square), len(pocket))
+	}
+	for _, tr := range pocket {
+		for _, v := range tr {
+			if v[2] != 0 && v[2] != -0.5 {
+				t.Errorf("pocket vertex %v is not on the sticker or its floor", v)
+			}
+		}
+	}
+}
+
+func TestPrintPieces(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	pieces, err := printPieces([]string{"R", "U"}, defaultCubeSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	min, max := vector{math.Inf(1), math.Inf(1), math.Inf(1)}, vector{math.Inf(-1), math.Inf(-1), math.Inf(-1)}
+	for _, p := range pieces {
+		count += len(p.Stickers)
+		for _, s := range p.Stickers {
+			for _, tr := range s.Triangles {
+				for _, v := range tr {
+					for i := range v {
+						min[i], max[i] = math.Min(min[i], v[i]), math.Max(max[i], v[i])
+					}
+				}
+			}
+		}
+	}
+
+	if count != faceletCount {
+		t.Errorf("sticker count must be %d, actual: %d", faceletCount, count)
+	}
+	if math.Abs(min[2]) > 1e-6 || math.Abs(max[2]-defaultCubeSize) > 1e-6 {
+		t.Errorf("z range must be [0, %f], actual: [%f, %f]", defaultCubeSize, min[2], max[2])
+	}
+}
+
+func TestBindPrintSize(t *testing.T) {
+	tests := []struct {
+		mm    string
+		valid bool
+	}{
+		{mm: "57", valid: true},
+		{mm: "1000", valid: true},
+		{mm: "0.5"},
+		{mm: "1001"},
+		{mm: "NaN"},
+		{mm: "Inf"},
+		{mm: "abc"},
+	}
+	for _, tt := range tests {
+		_, err := bindGetCubeHandlerRequest(url.Values{"format": {format3MF}, "mm": {tt.mm}})
+		if (err == nil) != tt.valid {
+			t.Errorf("mm=%s: error = %v", tt.mm, err)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	defaultAnimatedSize = 256
+	maxAnimatedSize     = 512
+	defaultFrameRate    = 20
+	maxFrameRate        = 50
+	maxAnimationFrames  = 600
+	// maxRenderSamples 1 リクエストで描画するサンプル数 (幅 × 高さ × renderSamples² × フレーム数) の上限。
+	// middleware.Timeout は描画を止めないため、描画の量で制限する。256 px・20 fps で約 12 秒のアニメーションまで
+	maxRenderSamples = 1 << 26
+	// renderSamples アンチエイリアスのための 1 ピクセルあたりの縦横のサンプル数
+	renderSamples = 2
+	// holdStart, holdEnd 最初と最後のフレームを表示し続ける時間 (秒)
+	holdStart = 0.5
+	holdEnd   = 1.0
+)
+
+// renderOptions キューブを 3D で描画した画像の設定
+type renderOptions struct {
+	Size       int
+	FrameRate  int
+	Loop       int // 再生回数。0 の場合は無限に繰り返す
+	Rotation   matrix
+	Background color.NRGBA
+}
+
+// frameEncoder アニメーション画像のフレームを順に受け取って 1 つの画像にする
+type frameEncoder interface {
+	add(img *image.NRGBA, delay float64) error
+	encode() ([]byte, error)
+}
+
+// renderPiece 描画するピースのプリミティブ (ノードの scale のみ適用した座標系) と色
+type renderPiece struct {
+	Translation vector
+	Primitives  []worldPrimitive
+	Colors      []color.NRGBA
+}
+
+// cubeRenderer ノードの rotation ごとにキューブを Z バッファー法で描画する
+type cubeRenderer struct {
+	pieces     []renderPiece
+	modelScale float64 // ワールド座標系を 1 辺 1 のキューブに合わせる倍率
+	view       matrix
+	background color.NRGBA
+	size       int
+	width      int // サンプル単位の幅
+	samples    []color.NRGBA
+	depth      []float64
+}
+
+// generateAnimatedImage 回転記号を再生するアニメーション GIF / APNG を生成する
+func generateAnimatedImage(format string, algorithm []string, animation animationOptions, options renderOptions) ([]byte, error) {
+	degrees, err := parseAlg(algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	times, rotations, err := animationKeyframes(gltfDoc.Nodes, degrees, animation)
+	if err != nil {
+		return nil, err
+	}
+
+	end := times[len(times)-1]
+	count := frameCount(end, options.FrameRate)
+	if err := checkRenderWork(options.Size, count); err != nil {
+		return nil, err
+	}
+
+	r, err := newCubeRenderer(gltfDoc, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var e frameEncoder
+	if format == formatAPNG {
+		e = newAPNGEncoder(options.Loop, options.Background.A < 0xff)
+	} else {
+		e = newGIFEncoder(options.Loop, options.Background.A < 0xff)
+	}
+
+	frame := make([][4]float64, len(rotations))
+	for i := 0; i < count; i++ {
+		t := math.Min(float64(i)/float64(options.FrameRate), end)
+		for j, keyframes := range rotations {
+			frame[j] = sampleKeyframes(times, keyframes, t)
+		}
+
+		delay := 1 / float64(options.FrameRate)
+		if i == 0 {
+			delay += holdStart
+		}
+		if i == count-1 {
+			delay = holdEnd
+		}
+		if err := e.add(r.render(frame), delay); err != nil {
+			return nil, err
+		}
+	}
+
+	return e.encode()
+}
+
+// frameCount end 秒のアニメーションを frameRate で描画するフレーム数を求める。最初と最後のフレームを含む
+func frameCount(end float64, frameRate int) int {
+	return int(math.Ceil(end*float64(frameRate)-1e-9)) + 1
+}
+
+// animationFrameCount 回転記号を再生するアニメーション画像のフレーム数を求める
+func animationFrameCount(algorithm []string, animation animationOptions, frameRate int) (int, error) {
+	degrees, err := parseAlg(algorithm)
+	if err != nil {
+		return 0, err
+	}
+	times, _, err := animationKeyframes(gltfDoc.Nodes, degrees, animation)
+	if err != nil {
+		return 0, err
+	}
+	return frameCount(times[len(times)-1], frameRate), nil
+}
+
+// checkRenderWork フレーム数と、描画するサンプル数の合計が上限を超えないことを確認する
+func checkRenderWork(size, frames int) error {
+	if frames > maxAnimationFrames {
+		return fmt.Errorf("animation is too long: %d frames (at most %d)", frames, maxAnimationFrames)
+	}
+	if samples := size * size * renderSamples * renderSamples * frames; samples > maxRenderSamples {
+		return fmt.Errorf("animation is too large to render: %d frames of %dpx (lower size, fps or duration)", frames, size)
+	}
+	return nil
+}
+
+// generateCubeImage 回転記号を moves 手目まで適用したキューブを 3D で描画した PNG を生成する
+func generateCubeImage(algorithm []string, moves float64, options renderOptions) ([]byte, error) {
+	nodes, err := applyAlgAt(gltfDoc.Nodes, algorithm, moves)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newCubeRenderer(gltfDoc, options)
+	if err != nil {
+		return nil, err
+	}
+
+	rotations := make([][4]float64, len(nodes))
+	for i, node := range nodes {
+		rotations[i] = node.RotationOrDefault()
+	}
+
+	buffer := new(bytes.Buffer)
+	if err := png.Encode(buffer, r.render(rotations)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// sampleKeyframes 時刻 t の rotation をキーフレームから球面線形補間で求める
+func sampleKeyframes(times []float64, keyframes [][4]float64, t float64) [4]float64 {
+	if t <= times[0] {
+		return keyframes[0]
+	}
+	for i := 1; i < len(times); i++ {
+		if t <= times[i] {
+			return slerp(keyframes[i-1], keyframes[i], (t-times[i-1])/(times[i]-times[i-1]))
+		}
+	}
+	return keyframes[len(keyframes)-1]
+}
+
+// newCubeRenderer ノードのメッシュと、マテリアルの色を読み取る
+func newCubeRenderer(doc *gltf.Document, options renderOptions) (*cubeRenderer, error) {
+	primitives, err := worldPrimitives(doc, doc.Nodes)
+	if err != nil {
+		return nil, err
+	}
+	min, max := bounds(primitives)
+	extent := math.Max(max[0]-min[0], math.Max(max[1]-min[1], max[2]-min[2]))
+
+	r := &cubeRenderer{
+		modelScale: 1 / extent,
+		view:       options.Rotation,
+		background: options.Background,
+		size:       options.Size,
+		width:      options.Size * renderSamples,
+	}
+	r.samples = make([]color.NRGBA, r.width*r.width)
+	r.depth = make([]float64, r.width*r.width)
+
+	for _, node := range doc.Nodes {
+		local, err := meshPrimitives(doc, node)
+		if err != nil {
+			return nil, err
+		}
+
+		piece := renderPiece{Translation: node.TranslationOrDefault()}
+		for _, p := range local {
+			piece.Primitives = append(piece.Primitives, p.transformed(node.ScaleOrDefault(), [4]float64{0, 0, 0, 1}, [3]float64{}))
+			piece.Colors = append(piece.Colors, materialColor(doc, p.Material))
+		}
+		r.pieces = append(r.pieces, piece)
+	}
+
+	return r, nil
+}
+
+// render ノードごとの rotation でキューブを描画する。ピースの並びは doc.Nodes と同じ
+func (r *cubeRenderer) render(rotations [][4]float64) *image.NRGBA {
+	for i := range r.samples {
+		r.samples[i] = r.background
+		r.depth[i] = 0
+	}
+
+	project := perspectiveProjection(r.view)
+	pixelScale := float64(r.width) / visualCubeViewBox[2]
+	light := normalize(vector{-0.3, 0.5, 1})
+
+	for i, piece := range r.pieces {
+		for j, p := range piece.Primitives {
+			view := make([]vector, len(p.Positions))
+			screen := make([]point, len(p.Positions))
+			for k, v := range p.Positions {
+				world := add(rotateVector(rotations[i], v), piece.Translation)
+				model := scaled(world, r.modelScale)
+				view[k] = r.view.apply(model)
+				s := project(model)
+				screen[k] = point{(s.X - visualCubeViewBox[0]) * pixelScale, (s.Y - visualCubeViewBox[1]) * pixelScale}
+			}
+
+			for k := 0; k+2 < len(p.Indices); k += 3 {
+				a, b, c := p.Indices[k], p.Indices[k+1], p.Indices[k+2]
+				normal := normalize(cross(add(view[b], scaled(view[a], -1)), add(view[c], scaled(view[a], -1))))
+				// カメラに背を向けた三角形は描画しない
+				if dot(normal, add(vector{0, 0, visualCubeDistance}, scaled(view[a], -1))) <= 0 {
+					continue
+				}
+
+				shade := 0.7 + 0.3*math.Max(0, dot(normal, light))
+				fill := piece.Colors[j]
+				fill.R = uint8(math.Round(float64(fill.R) * shade))
+				fill.G = uint8(math.Round(float64(fill.G) * shade))
+				fill.B = uint8(math.Round(float64(fill.B) * shade))
+
+				r.triangle(
+					[3]point{screen[a], screen[b], screen[c]},
+					[3]float64{
+						1 / (visualCubeDistance - view[a][2]),
+						1 / (visualCubeDistance - view[b][2]),
+						1 / (visualCubeDistance - view[c][2]),
+					},
+					fill,
+				)
+			}
+		}
+	}
+
+	return r.resolve()
+}
+
+// triangle 三角形をサンプル単位で塗る。w (カメラからの距離の逆数) が大きいほど手前にある
+func (r *cubeRenderer) triangle(p [3]point, w [3]float64, fill color.NRGBA) {
+	area := (p[1].X-p[0].X)*(p[2].Y-p[0].Y) - (p[1].Y-p[0].Y)*(p[2].X-p[0].X)
+	if area == 0 {
+		return
+	}
+
+	minX := int(math.Max(0, math.Floor(math.Min(p[0].X, math.Min(p[1].X, p[2].X)))))
+	maxX := int(math.Min(float64(r.width-1), math.Ceil(math.Max(p[0].X, math.Max(p[1].X, p[2].X)))))
+	minY := int(math.Max(0, math.Floor(math.Min(p[0].Y, math.Min(p[1].Y, p[2].Y)))))
+	maxY := int(math.Min(float64(r.width-1), math.Ceil(math.Max(p[0].Y, math.Max(p[1].Y, p[2].Y)))))
+
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			s := point{float64(x) + 0.5, float64(y) + 0.5}
+			b0 := ((p[1].X-s.X)*(p[2].Y-s.Y) - (p[1].Y-s.Y)*(p[2].X-s.X)) / area
+			b1 := ((p[2].X-s.X)*(p[0].Y-s.Y) - (p[2].Y-s.Y)*(p[0].X-s.X)) / area
+			b2 := 1 - b0 - b1
+			if b0 < 0 || b1 < 0 || b2 < 0 {
+				continue
+			}
+
+			i := y*r.width + x
+			if depth := b0*w[0] + b1*w[1] + b2*w[2]; depth > r.depth[i] {
+				r.depth[i] = depth
+				r.samples[i] = fill
+			}
+		}
+	}
+}
+
+// resolve サンプルを平均して画像にする
+func (r *cubeRenderer) resolve() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, r.size, r.size))
+	for y := 0; y < r.size; y++ {
+		for x := 0; x < r.size; x++ {
+			var sum [4]float64
+			for sy := 0; sy < renderSamples; sy++ {
+				for sx := 0; sx < renderSamples; sx++ {
+					c := r.samples[(y*renderSamples+sy)*r.width+x*renderSamples+sx]
+					a := float64(c.A)
+					sum[0] += float64(c.R) * a
+					sum[1] += float64(c.G) * a
+					sum[2] += float64(c.B) * a
+					sum[3] += a
+				}
+			}
+
+			if sum[3] == 0 {
+				continue
+			}
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(math.Round(sum[0] / sum[3])),
+				G: uint8(math.Round(sum[1] / sum[3])),
+				B: uint8(math.Round(sum[2] / sum[3])),
+				A: uint8(math.Round(sum[3] / (renderSamples * renderSamples))),
+			})
+		}
+	}
+	return img
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"image/color"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCubeRenderer(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	rotation, err := parseVisualCubeRotation(visualCubeDefaultRotation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const size = 64
+	r, err := newCubeRenderer(gltfDoc, renderOptions{Size: size, Rotation: rotation, Background: colorCodes['w']})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotations := make([][4]float64, len(gltfDoc.Nodes))
+	for i, node := range gltfDoc.Nodes {
+		rotations[i] = node.RotationOrDefault()
+	}
+	img := r.render(rotations)
+	if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+		t.Fatalf("image size must be %dx%d, actual: %v", size, size, b)
+	}
+
+	// 既定の視点では、上に U 面 (黄)、左下に F 面 (青)、右下に R 面 (赤) が見える
+	tests := []struct {
+		name string
+		x, y int
+		is   func(c color.NRGBA) bool
+	}{
+		{name: "background", x: 1, y: 1, is: func(c color.NRGBA) bool { return c == colorCodes['w'] }},
+		{name: "U", x: size / 2, y: size / 5, is: func(c color.NRGBA) bool { return c.R > 0x80 && c.G > 0x80 && c.B < 0x40 }},
+		{name: "F", x: size / 3, y: size * 3 / 5, is: func(c color.NRGBA) bool { return c.B > 0x80 && c.R < 0x40 }},
+		{name: "R", x: size * 2 / 3, y: size * 3 / 5, is: func(c color.NRGBA) bool { return c.R > 0x80 && c.G < 0x40 && c.B < 0x40 }},
+	}
+	for _, tt := range tests {
+		if c := img.NRGBAAt(tt.x, tt.y); !tt.is(c) {
+			t.Errorf("%s: unexpected color at (%d, %d): %v", tt.name, tt.x, tt.y, c)
+		}
+	}
+}
+
+func TestCheckRenderWork(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query string
+		valid bool
+	}{
+		{query: "format=png&size=512", valid: true},
+		{query: "format=gif&alg=R+U+R'+U'", valid: true},
+		{query: "format=gif&size=512&fps=50&alg=" + url.QueryEscape("R U R' U' R' F R2 U' R' U' R U")},
+		{query: "format=apng&size=256&duration=10&alg=R+U"},
+	}
+	for _, tt := range tests {
+		v, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = bindGetCubeHandlerRequest(v)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s: error = %v", tt.query, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), "animation is too") {
+			t.Errorf("%s: unexpected error: %v", tt.query, err)
+		}
+	}
+}
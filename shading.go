@@ -0,0 +1,256 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	extensionMaterialsUnlit = "KHR_materials_unlit"
+	extensionLightsPunctual = "KHR_lights_punctual"
+)
+
+const (
+	// bodyRoughness pbr の本体のプラスチックの粗さ
+	bodyRoughness = 0.6
+	// stickerRoughness pbr のステッカーや文字などの粗さ
+	stickerRoughness = 0.35
+	// groundSize 地面の 1 辺の長さ (キューブの 1 辺に対する割合)
+	groundSize = 4.0
+	// groundGap キューブの最も低い位置と地面の間の距離 (キューブの 1 辺に対する割合)
+	groundGap = 0.005
+)
+
+// groundColor 影を受ける地面の色
+var groundColor = color.NRGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+
+// punctualLight KHR_lights_punctual の 1 つのライト
+type punctualLight struct {
+	Name      string     `json:"name"`
+	Type      string     `json:"type"`
+	Color     [3]float64 `json:"color"`
+	Intensity float64    `json:"intensity"`
+}
+
+// lightsPunctual KHR_lights_punctual のルートの拡張
+type lightsPunctual struct {
+	Lights []punctualLight `json:"lights"`
+}
+
+// nodeLight KHR_lights_punctual のノードの拡張
+type nodeLight struct {
+	Light uint32 `json:"light"`
+}
+
+// studioLights 右上手前からのキーライト、左手前からの補助光、奥からの輪郭光。
+// from はキューブから見たライトの向き (glTF の座標系) で、intensity は lux
+var studioLights = []struct {
+	name      string
+	from      vector
+	intensity float64
+}{
+	{name: "Key Light", from: vector{1, 2, 1.5}, intensity: 3},
+	{name: "Fill Light", from: vector{-1.5, 0.5, 1}, intensity: 1},
+	{name: "Rim Light", from: vector{0, 1, -2}, intensity: 1.5},
+}
+
+// applyPBR すべてのマテリアルから KHR_materials_unlit を除き、プラスチックの metallic・roughness を設定する
+func applyPBR(doc *gltf.Document) {
+	base, hasBase := baseMaterialIndex(doc)
+	for i, m := range doc.Materials {
+		// copyMaterial で複製したマテリアルは拡張を共有するため、書き換えずに作り直す
+		if len(m.Extensions) > 0 {
+			extensions := make(gltf.Extensions, len(m.Extensions))
+			for k, v := range m.Extensions {
+				if k != extensionMaterialsUnlit {
+					extensions[k] = v
+				}
+			}
+			m.Extensions = extensions
+		}
+		if m.PBRMetallicRoughness == nil {
+			m.PBRMetallicRoughness = new(gltf.PBRMetallicRoughness)
+		}
+		roughness := stickerRoughness
+		if hasBase && uint32(i) == base {
+			roughness = bodyRoughness
+		}
+		m.PBRMetallicRoughness.MetallicFactor = gltf.Float64(0)
+		m.PBRMetallicRoughness.RoughnessFactor = gltf.Float64(roughness)
+	}
+
+	var used []string
+	for _, e := range doc.ExtensionsUsed {
+		if e != extensionMaterialsUnlit {
+			used = append(used, e)
+		}
+	}
+	doc.ExtensionsUsed = used
+}
+
+// addLighting pbr の場合に、設定に合わせて KHR_lights_punctual のライトと影を受ける地面をすべてのシーンに加え、
+// 加えたノードのインデックスを返す。隠したピースを除いた後に呼ぶ。
+// 地面の高さは s.View の回転を適用した位置で求め、ノードはシーンごとに複製する
+func (s cubeStyle) addLighting(doc *gltf.Document, animated bool) (map[uint32]bool, error) {
+	var nodes []*gltf.Node
+	if s.Lights {
+		nodes = append(nodes, addStudioLights(doc)...)
+	}
+	if s.Ground {
+		ground, err := s.addGround(doc, animated)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, ground)
+	}
+
+	added := make(map[uint32]bool)
+	for _, scene := range doc.Scenes {
+		for _, node := range nodes {
+			n := *node
+			doc.Nodes = append(doc.Nodes, &n)
+			added[uint32(len(doc.Nodes)-1)] = true
+			scene.Nodes = append(scene.Nodes, uint32(len(doc.Nodes)-1))
+		}
+	}
+	return added, nil
+}
+
+// addStudioLights studioLights を KHR_lights_punctual の平行光源として追加し、それを置くノードを作る
+func addStudioLights(doc *gltf.Document) []*gltf.Node {
+	var (
+		root  lightsPunctual
+		nodes []*gltf.Node
+	)
+	for i, l := range studioLights {
+		root.Lights = append(root.Lights, punctualLight{Name: l.name, Type: "directional", Color: [3]float64{1, 1, 1}, Intensity: l.intensity})
+		nodes = append(nodes, &gltf.Node{
+			Name:       l.name,
+			Rotation:   directionRotation(scaled(normalize(l.from), -1)),
+			Extensions: gltf.Extensions{extensionLightsPunctual: nodeLight{Light: uint32(i)}},
+		})
+	}
+
+	if doc.Extensions == nil {
+		doc.Extensions = make(gltf.Extensions)
+	}
+	doc.Extensions[extensionLightsPunctual] = root
+	doc.ExtensionsUsed = append(doc.ExtensionsUsed, extensionLightsPunctual)
+	return nodes
+}
+
+// directionRotation 平行光源が照らす向きの -Z を、単位ベクトル direction に向ける rotation を求める
+func directionRotation(direction vector) [4]float64 {
+	forward := vector{0, 0, -1}
+	axis := cross(forward, direction)
+	if norm(axis) < 1e-9 {
+		if dot(forward, direction) > 0 {
+			return [4]float64{0, 0, 0, 1}
+		}
+		return axisAngleQuaternion(vector{0, 1, 0}, math.Pi)
+	}
+	return axisAngleQuaternion(normalize(axis), math.Acos(math.Max(-1, math.Min(1, dot(forward, direction)))))
+}
+
+// addGround シーン内のピースの最も低い位置のすぐ下に置く、上を向いた正方形の地面のメッシュを追加し、それを置くノードを作る。
+// 高さは視点の回転を適用した後の位置で求める。animated の場合は、層が回る途中で下に出る分も空ける
+func (s cubeStyle) addGround(doc *gltf.Document, animated bool) (*gltf.Node, error) {
+	extent, err := solvedExtent()
+	if err != nil {
+		return nil, err
+	}
+	view := identityMatrix
+	if s.View != nil {
+		view = s.View.Rotation
+	}
+
+	// 複製した glTF はバッファーのデータを持たないため、ピースのメッシュは cube.gltf から読み取る
+	meshes := make(map[string]*gltf.Node, len(gltfDoc.Nodes))
+	for _, node := range gltfDoc.Nodes {
+		meshes[node.Name] = node
+	}
+	var primitives []worldPrimitive
+	for _, scene := range doc.Scenes {
+		for _, i := range scene.Nodes {
+			node := doc.Nodes[i]
+			piece, ok := meshes[node.Name]
+			if !ok {
+				continue
+			}
+			local, err := meshPrimitives(gltfDoc, piece)
+			if err != nil {
+				return nil, err
+			}
+			for _, p := range local {
+				primitives = append(primitives, p.transformed(node.ScaleOrDefault(), node.RotationOrDefault(), node.TranslationOrDefault()))
+			}
+		}
+	}
+	min, max := bounds(primitives)
+	bottom := math.Inf(1)
+	for _, p := range primitives {
+		for _, v := range p.Positions {
+			bottom = math.Min(bottom, view.apply(v)[1])
+		}
+	}
+	// 層はキューブの軸まわりに回るため、ピースを囲む直方体の頂点がそれぞれの軸まわりに回ったときの最も低い位置まで空ける
+	if animated {
+		for _, corner := range boxCorners(min, max) {
+			for _, axis := range identityMatrix {
+				bottom = math.Min(bottom, turnBottom(view.apply(corner), view.apply(axis)))
+			}
+		}
+	}
+	// 鏡のノードは子だけがメッシュを持つため、鏡に映したステッカーの位置は距離から求める
+	if s.Mirrors > 0 {
+		for _, face := range mirrorFaces {
+			axes := faceAxes[face]
+			center := scaled(axes.normal, extent/2+s.Mirrors*extent)
+			for _, i := range []float64{-1, 1} {
+				for _, j := range []float64{-1, 1} {
+					corner := add(center, add(scaled(axes.right, i*extent/2), scaled(axes.down, j*extent/2)))
+					bottom = math.Min(bottom, view.apply(corner)[1])
+				}
+			}
+		}
+	}
+	y := bottom - groundGap*extent
+
+	half := groundSize * extent / 2
+	positions := []vector{{-half, y, -half}, {-half, y, half}, {half, y, half}, {half, y, -half}}
+
+	m := &gltf.Material{
+		Name: "Ground",
+		PBRMetallicRoughness: &gltf.PBRMetallicRoughness{
+			MetallicFactor:  gltf.Float64(0),
+			RoughnessFactor: gltf.Float64(1),
+		},
+	}
+	setMaterialColor(m, groundColor)
+	doc.Materials = append(doc.Materials, m)
+
+	mesh := addMesh(doc, "Ground", positions, []uint32{0, 1, 2, 0, 2, 3}, uint32(len(doc.Materials)-1))
+	return &gltf.Node{Name: "Ground", Mesh: gltf.Index(mesh)}, nil
+}
+
+// boxCorners min と max を対角とする直方体の 8 個の頂点を取得する
+func boxCorners(min, max vector) []vector {
+	corners := make([]vector, 0, 8)
+	for _, x := range []float64{min[0], max[0]} {
+		for _, y := range []float64{min[1], max[1]} {
+			for _, z := range []float64{min[2], max[2]} {
+				corners = append(corners, vector{x, y, z})
+			}
+		}
+	}
+	return corners
+}
+
+// turnBottom 原点を通る単位ベクトル axis まわりに点 p を回したときの、最も低い y 座標を求める
+func turnBottom(p, axis vector) float64 {
+	center := scaled(axis, dot(p, axis))
+	radius := norm(add(p, scaled(center, -1)))
+	return center[1] - radius*math.Sqrt(math.Max(0, 1-axis[1]*axis[1]))
+}
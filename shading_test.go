@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyPBR(t *testing.T) {
+	doc := copyCubeDocument(t)
+	if err := (cubeStyle{PBR: true, Symbols: true}).apply(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range doc.ExtensionsUsed {
+		if e == extensionMaterialsUnlit {
+			t.Errorf("extensionsUsed must not contain %s", e)
+		}
+	}
+	for _, m := range doc.Materials {
+		if _, ok := m.Extensions[extensionMaterialsUnlit]; ok {
+			t.Errorf("%s must not be unlit", m.Name)
+		}
+		if m.PBRMetallicRoughness.MetallicFactorOrDefault() != 0 {
+			t.Errorf("%s must not be metallic", m.Name)
+		}
+	}
+	// 元の cube.gltf のマテリアルは書き換えない
+	if _, ok := gltfDoc.Materials[0].Extensions[extensionMaterialsUnlit]; !ok {
+		t.Error("materials of cube.gltf must stay unlit")
+	}
+}
+
+func TestAddLighting(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	extent, err := solvedExtent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		style    cubeStyle
+		animated bool
+		// bottom 地面の高さの上限 (キューブの 1 辺に対する割合)
+		bottom float64
+	}{
+		{style: cubeStyle{PBR: true, Lights: true, Ground: true}, bottom: -0.5},
+		{style: cubeStyle{PBR: true, Ground: true}, animated: true, bottom: -0.5 * math.Sqrt2},
+		{style: cubeStyle{PBR: true, Ground: true, Explode: 1}, bottom: -0.5 - 1.0/3},
+		{style: cubeStyle{PBR: true, Ground: true, Mirrors: 1}, bottom: -1.5},
+	}
+	for _, tt := range tests {
+		doc := copyCubeDocument(t)
+		// 生成時と同じく、埋め込みバッファーのデータを持たない複製で地面の高さを求める
+		doc.Buffers = nil
+		if err := explodeNodes(doc.Nodes, tt.style.Explode); err != nil {
+			t.Fatal(err)
+		}
+		added, err := tt.style.addLighting(doc, tt.animated)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lights, ground := 0, false
+		for _, i := range doc.Scenes[0].Nodes {
+			node := doc.Nodes[i]
+			_, light := node.Extensions[extensionLightsPunctual]
+			if added[i] != (light || node.Name == "Ground") {
+				t.Errorf("%+v: %s must be returned only if it is a light or the ground", tt.style, node.Name)
+			}
+			if light, ok := node.Extensions[extensionLightsPunctual].(nodeLight); ok {
+				// ライトは上からキューブの方を照らす
+				l := studioLights[light.Light]
+				direction := rotateVector(node.RotationOrDefault(), vector{0, 0, -1})
+				if d := dot(direction, normalize(l.from)); math.Abs(d+1) > 1e-9 {
+					t.Errorf("%s must point to the cube: %v", l.name, direction)
+				}
+				lights++
+			}
+			if node.Name != "Ground" {
+				continue
+			}
+			ground = true
+			position := doc.Accessors[doc.Meshes[*node.Mesh].Primitives[0].Attributes["POSITION"]]
+			if y := position.Max[1]; y > tt.bottom*extent {
+				t.Errorf("%+v: ground must be under %g: %g", tt.style, tt.bottom*extent, y)
+			}
+		}
+
+		count := 0
+		if tt.style.Lights {
+			count = len(studioLights)
+		}
+		if lights != count {
+			t.Errorf("%+v: lights must be %d, actual: %d", tt.style, count, lights)
+		}
+		if _, ok := doc.Extensions[extensionLightsPunctual]; ok != tt.style.Lights {
+			t.Errorf("%+v: %s must be added only with lights", tt.style, extensionLightsPunctual)
+		}
+		if !ground {
+			t.Errorf("%+v: ground must be added", tt.style)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	maxSheetCubes = 200
+	// sheetSpacing キューブの 1 辺に対する、並べたキューブの中心どうしの距離
+	sheetSpacing = 1.5
+)
+
+// sheetCube シートに並べる 1 つのキューブ
+type sheetCube struct {
+	Algorithm []string
+	Label     string
+}
+
+// generateSheet 複数の回転記号を適用したキューブを、1 つの glTF に格子状に並べる。
+// キューブごとに親ノードを作り、その子に cube.gltf と同じメッシュを参照するノードを置く。
+// 行は上から下 (-Y)、列は左から右 (+X) に並べ、全体の中心を原点にする
+func generateSheet(cubes []sheetCube, columns int) ([]byte, error) {
+	var doc gltf.Document
+
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		return nil, err
+	}
+
+	extent, err := solvedExtent()
+	if err != nil {
+		return nil, err
+	}
+	spacing := extent * sheetSpacing
+
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(len(cubes)))))
+	}
+	rows := (len(cubes) + columns - 1) / columns
+	if len(cubes) < columns {
+		columns = len(cubes)
+	}
+
+	base := doc.Nodes
+	doc.Nodes = nil
+	scene := &gltf.Scene{Name: "Sheet"}
+	for i, cube := range cubes {
+		nodes, err := applyAlg(base, cube.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		name := cube.Label
+		if name == "" {
+			name = fmt.Sprintf("Cube %d", i+1)
+		}
+		parent := &gltf.Node{
+			Name: name,
+			Translation: [3]float64{
+				(float64(i%columns) - float64(columns-1)/2) * spacing,
+				(float64(rows-1)/2 - float64(i/columns)) * spacing,
+				0,
+			},
+			Extras: map[string]string{"alg": strings.Join(cube.Algorithm, " ")},
+		}
+		for _, node := range nodes {
+			doc.Nodes = append(doc.Nodes, node)
+			parent.Children = append(parent.Children, uint32(len(doc.Nodes)-1))
+		}
+
+		doc.Nodes = append(doc.Nodes, parent)
+		scene.Nodes = append(scene.Nodes, uint32(len(doc.Nodes)-1))
+	}
+	doc.Scenes = []*gltf.Scene{scene}
+	doc.Scene = gltf.Index(0)
+
+	return encodeGltf(&doc)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+)
+
+func TestBindSheetRequest(t *testing.T) {
+	tests := []struct {
+		body    string
+		cubes   int
+		columns int
+		valid   bool
+	}{
+		{body: `{"algs": [{"alg": "R U R' U'", "label": "Sexy"}, {"alg": ""}], "columns": 7}`, cubes: 2, columns: 7, valid: true},
+		{body: `{"algs": [{"alg": "F2 B2"}]}`, cubes: 1, valid: true},
+		{body: `{"algs": []}`},
+		{body: `{"algs": [{"alg": "R"}], "columns": -1}`},
+		{body: `{"algs": [{"alg": "r u"}]}`},
+		{body: `{"algs": [{"alg": "R x"}]}`},
+		{body: `{"algs": [{"alg": "R"}`},
+		{body: `{"algs": [` + strings.Repeat(`{"alg": "R"},`, maxSheetCubes) + `{"alg": "R"}]}`},
+	}
+	for _, tt := range tests {
+		cubes, columns, err := bindSheetRequest(strings.NewReader(tt.body))
+		if (err == nil) != tt.valid {
+			t.Errorf("%.40s: error = %v", tt.body, err)
+			continue
+		}
+		if tt.valid && (len(cubes) != tt.cubes || columns != tt.columns) {
+			t.Errorf("%.40s: cubes and columns must be %d, %d, actual: %d, %d", tt.body, tt.cubes, tt.columns, len(cubes), columns)
+		}
+	}
+}
+
+func TestGenerateSheet(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	extent, err := solvedExtent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cubes := []sheetCube{
+		{Algorithm: []string{"R", "U"}, Label: "First"},
+		{Algorithm: []string{"F2"}},
+		{},
+	}
+	data, err := generateSheet(cubes, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := new(gltf.Document)
+	if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	// キューブどうしでメッシュを共有し、cube.gltf から増やさない
+	if len(doc.Meshes) != len(gltfDoc.Meshes) {
+		t.Errorf("mesh count must be %d, actual: %d", len(gltfDoc.Meshes), len(doc.Meshes))
+	}
+
+	// 2 列 2 行で、全体の中心が原点になる
+	spacing := extent * sheetSpacing
+	want := []struct {
+		name string
+		x, y float64
+	}{
+		{name: "First", x: -spacing / 2, y: spacing / 2},
+		{name: "Cube 2", x: spacing / 2, y: spacing / 2},
+		{name: "Cube 3", x: -spacing / 2, y: -spacing / 2},
+	}
+	roots := doc.Scenes[*doc.Scene].Nodes
+	if len(roots) != len(want) {
+		t.Fatalf("scene must have %d cubes, actual: %d", len(want), len(roots))
+	}
+	for i, w := range want {
+		parent := doc.Nodes[roots[i]]
+		if parent.Name != w.name {
+			t.Errorf("cube %d must be named %q, actual: %q", i, w.name, parent.Name)
+		}
+		if p := parent.Translation; math.Abs(p[0]-w.x) > 1e-6 || math.Abs(p[1]-w.y) > 1e-6 || p[2] != 0 {
+			t.Errorf("%s must be at (%g, %g, 0), actual: %v", w.name, w.x, w.y, p)
+		}
+		if len(parent.Children) != len(gltfDoc.Nodes) {
+			t.Errorf("%s must have %d pieces, actual: %d", w.name, len(gltfDoc.Nodes), len(parent.Children))
+		}
+
+		applied, err := applyAlg(gltfDoc.Nodes, cubes[i].Algorithm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rotations := nodeRotations(applied)
+		for _, c := range parent.Children {
+			node := doc.Nodes[c]
+			r, w := node.RotationOrDefault(), rotations[node.Name]
+			if d := r[0]*w[0] + r[1]*w[1] + r[2]*w[2] + r[3]*w[3]; math.Abs(math.Abs(d)-1) > 1e-6 {
+				t.Errorf("%s: %s rotation must be %v, actual: %v", parent.Name, node.Name, w, r)
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// stagePredicate 完成状態でのピースの位置と面の法線から、そのステッカーを表示するかを判定する
+type stagePredicate func(piece, normal vector) bool
+
+var (
+	upNormal = vector{0, 1, 0}
+
+	// firstTwoLayers D 面から 2 層 (F2L)
+	firstTwoLayers stagePredicate = func(p, n vector) bool { return p[1] <= 0 }
+	// lastLayer U 面の 1 層
+	lastLayer stagePredicate = func(p, n vector) bool { return p[1] == 1 }
+	// lastLayerTop 最終層の U 面を向いたステッカー
+	lastLayerTop stagePredicate = func(p, n vector) bool { return p[1] == 1 && n == upNormal }
+	// crossStage D 面のクロスと、中層のセンター
+	crossStage stagePredicate = func(p, n vector) bool {
+		return (p[1] == -1 && pieceKind(p) != cornerPiece) || (p[1] == 0 && pieceKind(p) == centerPiece)
+	}
+	// lineStage D 面の DF・DB エッジとセンター、中層のセンター
+	lineStage stagePredicate = func(p, n vector) bool {
+		return p[0] == 0 && crossStage(p, n)
+	}
+)
+
+// stages VisualCube の stage パラメーターで指定できるマスク
+var stages = map[string]stagePredicate{
+	"fl":  func(p, n vector) bool { return p[1] == -1 },
+	"f2l": firstTwoLayers,
+	"ll":  lastLayer,
+	"cll": func(p, n vector) bool { return lastLayer(p, n) && pieceKind(p) != edgePiece },
+	"ell": func(p, n vector) bool { return lastLayer(p, n) && pieceKind(p) != cornerPiece },
+	"oll": lastLayerTop,
+	"ocll": func(p, n vector) bool {
+		return lastLayerTop(p, n) && pieceKind(p) != edgePiece
+	},
+	"oell": func(p, n vector) bool {
+		return lastLayerTop(p, n) && pieceKind(p) != cornerPiece
+	},
+	"coll": func(p, n vector) bool {
+		return lastLayer(p, n) && (pieceKind(p) != edgePiece || n == upNormal)
+	},
+	"ocell": lastLayerTop,
+	"wv": func(p, n vector) bool {
+		return firstTwoLayers(p, n) || lastLayerTop(p, n)
+	},
+	"vh": func(p, n vector) bool {
+		return firstTwoLayers(p, n) || (lastLayerTop(p, n) && pieceKind(p) != cornerPiece)
+	},
+	"els": func(p, n vector) bool {
+		return firstTwoLayers(p, n) && p != vector{1, 0, 1}
+	},
+	"cls": func(p, n vector) bool {
+		return firstTwoLayers(p, n) || lastLayerTop(p, n)
+	},
+	"cmll": func(p, n vector) bool {
+		return (p[0] != 0 && p[1] <= 0) || (p[1] == 1 && pieceKind(p) == cornerPiece)
+	},
+	"pll":   lastLayer,
+	"fb":    func(p, n vector) bool { return p[0] == -1 && p[1] <= 0 },
+	"f2b":   func(p, n vector) bool { return p[0] != 0 && p[1] <= 0 },
+	"cross": crossStage,
+	"line":  lineStage,
+	// eoline ZZ の EOLine。line に加えて、エッジの向きを表す U/D 面 (E 層は F/B 面) のステッカー
+	"eoline": func(p, n vector) bool {
+		if lineStage(p, n) {
+			return true
+		}
+		return pieceKind(p) == edgePiece && (n[1] != 0 || (p[1] == 0 && n[2] != 0))
+	},
+	"f2l_1": func(p, n vector) bool {
+		return crossStage(p, n) || (p[0] == -1 && p[2] == -1 && p[1] <= 0)
+	},
+	"f2l_2": func(p, n vector) bool {
+		return firstTwoLayers(p, n) && !(p[0] != 0 && p[2] == 1)
+	},
+	"f2l_3": func(p, n vector) bool {
+		return firstTwoLayers(p, n) && !(p[0] == 1 && p[2] == 1)
+	},
+	"2x2x2": func(p, n vector) bool { return p[0] <= 0 && p[1] <= 0 && p[2] <= 0 },
+	"2x2x3": func(p, n vector) bool { return p[1] <= 0 && p[2] <= 0 },
+}
+
+type pieceType int
+
+const (
+	centerPiece pieceType = iota + 1
+	edgePiece
+	cornerPiece
+)
+
+// pieceKind ピースの位置から種類を判定する
+func pieceKind(piece vector) pieceType {
+	count := 0
+	for _, v := range piece {
+		if v != 0 {
+			count++
+		}
+	}
+	return pieceType(count)
+}
+
+var cubeRotationRegex = regexp.MustCompile(`^(?:[xyz](?:2|')?)+$`)
+
+// parseCubeRotation キューブ全体の回転記号 (x, y', z2 など) を回転行列に変換する
+func parseCubeRotation(s string) (matrix, error) {
+	if !cubeRotationRegex.MatchString(s) {
+		return identityMatrix, fmt.Errorf("unknown cube rotation: %s", s)
+	}
+
+	m := identityMatrix
+	for i := 0; i < len(s); i++ {
+		// x は R、y は U、z は F と同じ向きに回転する
+		turns := 1.0
+		if i+1 < len(s) && s[i+1] == '2' {
+			turns = 2
+		} else if i+1 < len(s) && s[i+1] == '\'' {
+			turns = -1
+		}
+		m = axisRotation(int(s[i]-'x'), -turns*math.Pi/2).mul(m)
+		if turns != 1 {
+			i++
+		}
+	}
+
+	return roundMatrix(m), nil
+}
+
+// roundMatrix 90 度単位の回転行列の誤差を丸める
+func roundMatrix(m matrix) matrix {
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = math.Round(m[i][j])
+		}
+	}
+	return m
+}
+
+// stageMask ステージ名からマスクするファセットを完成状態の位置で取得する。
+// "cross-x2" のように、キューブ全体の回転を接尾辞で指定できる。
+// "f2l,UFR,UF" のようにカンマ区切りでステージ名とピース名 (大文字) を並べると、いずれかで表示するファセットを表示する
+func stageMask(stage string) ([faceletCount]bool, error) {
+	var mask [faceletCount]bool
+
+	var predicates []stagePredicate
+	for _, term := range strings.Split(stage, ",") {
+		predicate, err := stageTerm(term)
+		if err != nil {
+			return mask, err
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	for i := range mask {
+		piece, normal := faceletPiece(i)
+		mask[i] = true
+		for _, predicate := range predicates {
+			if predicate(piece, normal) {
+				mask[i] = false
+			}
+		}
+	}
+
+	return mask, nil
+}
+
+// stageTerm ステージ名 (回転の接尾辞付き) またはピース名を、完成状態の位置で判定する関数に変換する
+func stageTerm(term string) (stagePredicate, error) {
+	if piece, ok := pieceSelector(term); ok {
+		return func(p, n vector) bool { return p == piece }, nil
+	}
+
+	name, rotation := term, identityMatrix
+	if i := strings.Index(term, "-"); i >= 0 {
+		var err error
+		name = term[:i]
+		if rotation, err = parseCubeRotation(term[i+1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	predicate, ok := stages[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown stage: %s", name)
+	}
+
+	inverse := rotation.transpose()
+	return func(p, n vector) bool { return predicate(inverse.apply(p), inverse.apply(n)) }, nil
+}
+
+// pieceSelector UFR や DL、F のような大文字の面の組み合わせを、完成状態のピースの位置に変換する
+func pieceSelector(s string) (vector, bool) {
+	var piece vector
+	if s == "" || len(s) > 3 {
+		return piece, false
+	}
+	for i := 0; i < len(s); i++ {
+		face := strings.IndexByte("URFDLB", s[i])
+		if face < 0 {
+			return piece, false
+		}
+		normal := faceAxes[face].normal
+		for axis, v := range normal {
+			if v == 0 {
+				continue
+			}
+			if piece[axis] != 0 {
+				return piece, false
+			}
+			piece[axis] = v
+		}
+	}
+	return piece, true
+}
+
+// pieceName 位置を pieceSelector で指定できるピース名 (U/D、F/B、R/L の順。例: UFR、FR) にする
+func pieceName(piece vector) string {
+	var name string
+	for _, f := range []Face{FaceU, FaceD, FaceF, FaceB, FaceR, FaceL} {
+		if dot(piece, faceAxes[f].normal) == 1 {
+			name += faceNames[f]
+		}
+	}
+	return name
+}
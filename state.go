@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/qmuntal/gltf"
+)
+
+// Face キューブの面。並び順は VisualCube のファセット順 (U R F D L B) に合わせる
+type Face int
+
+const (
+	FaceU Face = iota
+	FaceR
+	FaceF
+	FaceD
+	FaceL
+	FaceB
+)
+
+const faceletCount = 54
+
+var faceNames = [6]string{"U", "R", "F", "D", "L", "B"}
+
+// faceAxes 各面の法線と、展開図上でその面を見たときの右方向・下方向 (glTF の座標系)
+var faceAxes = [6]struct{ normal, right, down vector }{
+	FaceU: {vector{0, 1, 0}, vector{1, 0, 0}, vector{0, 0, 1}},
+	FaceR: {vector{1, 0, 0}, vector{0, 0, -1}, vector{0, -1, 0}},
+	FaceF: {vector{0, 0, 1}, vector{1, 0, 0}, vector{0, -1, 0}},
+	FaceD: {vector{0, -1, 0}, vector{1, 0, 0}, vector{0, 0, -1}},
+	FaceL: {vector{-1, 0, 0}, vector{0, 0, 1}, vector{0, -1, 0}},
+	FaceB: {vector{0, 0, -1}, vector{-1, 0, 0}, vector{0, -1, 0}},
+}
+
+// Sticker cube.gltf の各ノードのメッシュに含まれる、外側を向いたステッカーのプリミティブ
+type Sticker struct {
+	Node      string
+	Primitive int
+	Material  uint32
+	Center    vector // ノードの scale を適用した局所座標系でのステッカーの中心
+	Home      int    // 完成状態でステッカーがあるファセット番号
+}
+
+// State ファセット番号ごとに、その位置にある stickers のインデックスを持つ
+type State [faceletCount]int
+
+var (
+	stickers        []Sticker
+	stickerDistance float64
+)
+
+// initStickers 完成状態の glTF からステッカーの位置を読み取る
+func initStickers(doc *gltf.Document) error {
+	var candidates []Sticker
+	for _, node := range doc.Nodes {
+		if node.Mesh == nil {
+			continue
+		}
+		scale := node.ScaleOrDefault()
+		for i, p := range doc.Meshes[*node.Mesh].Primitives {
+			if p.Material == nil || doc.Materials[*p.Material].Name == "BaseColor" {
+				continue
+			}
+			positions, err := readVec3(doc, p.Attributes["POSITION"])
+			if err != nil {
+				return err
+			}
+			var center vector
+			for _, v := range positions {
+				for j := range center {
+					center[j] += v[j] * scale[j] / float64(len(positions))
+				}
+			}
+			candidates = append(candidates, Sticker{
+				Node:      node.Name,
+				Primitive: i,
+				Material:  *p.Material,
+				Center:    center,
+			})
+		}
+	}
+
+	rotations := nodeRotations(doc.Nodes)
+	for _, s := range candidates {
+		if d, _ := outerFace(rotateVector(rotations[s.Node], s.Center)); d > stickerDistance {
+			stickerDistance = d
+		}
+	}
+
+	stickers = nil
+	for _, s := range candidates {
+		home, ok := faceletAt(rotateVector(rotations[s.Node], s.Center))
+		if !ok {
+			continue
+		}
+		s.Home = home
+		stickers = append(stickers, s)
+	}
+	if len(stickers) != faceletCount {
+		return fmt.Errorf("sticker count must be %d, actual: %d", faceletCount, len(stickers))
+	}
+
+	return nil
+}
+
+// stateOf ノードの回転からファセットの状態を求める
+func stateOf(nodes []*gltf.Node) (State, error) {
+	var (
+		state  State
+		filled [faceletCount]bool
+	)
+
+	rotations := nodeRotations(nodes)
+	for i, s := range stickers {
+		r, ok := rotations[s.Node]
+		if !ok {
+			return state, fmt.Errorf("node not found: %s", s.Node)
+		}
+		f, ok := faceletAt(rotateVector(r, s.Center))
+		if !ok || filled[f] {
+			return state, fmt.Errorf("sticker of %s is out of place", s.Node)
+		}
+		state[f] = i
+		filled[f] = true
+	}
+
+	return state, nil
+}
+
+// nodeRotations ノード名と rotation の対応を取得する
+func nodeRotations(nodes []*gltf.Node) map[string][4]float64 {
+	rotations := make(map[string][4]float64, len(nodes))
+	for _, n := range nodes {
+		rotations[n.Name] = n.RotationOrDefault()
+	}
+	return rotations
+}
+
+// outerFace 座標が最も外側にある面と、その面方向の距離を取得する
+func outerFace(p vector) (float64, Face) {
+	dist, face := math.Inf(-1), FaceU
+	for f, axes := range faceAxes {
+		if d := dot(p, axes.normal); d > dist {
+			dist, face = d, Face(f)
+		}
+	}
+	return dist, face
+}
+
+// faceletAt 座標にあるファセット番号を取得する。内側を向いたステッカーの場合は false を返す
+func faceletAt(p vector) (int, bool) {
+	dist, face := outerFace(p)
+	if dist < stickerDistance*0.9 {
+		return 0, false
+	}
+
+	// 面の中心からステッカー 1 枚分ずれた位置が ±1 になる
+	unit := dist / 1.5
+	col := int(math.Round(dot(p, faceAxes[face].right)/unit)) + 1
+	row := int(math.Round(dot(p, faceAxes[face].down)/unit)) + 1
+	if col < 0 || col > 2 || row < 0 || row > 2 {
+		return 0, false
+	}
+
+	return int(face)*9 + row*3 + col, true
+}
+
+// faceletPiece 完成状態でファセットが属するピースの位置 (各成分が -1, 0, 1) と面の法線を取得する
+func faceletPiece(facelet int) (vector, vector) {
+	axes := faceAxes[facelet/9]
+	col, row := float64(facelet%3-1), float64(facelet%9/3-1)
+
+	var piece vector
+	for i := range piece {
+		piece[i] = axes.normal[i] + axes.right[i]*col + axes.down[i]*row
+	}
+
+	return piece, axes.normal
+}
+
+// nodeHome ノード名 (例: UFR) から、完成状態でのピースの位置を取得する
+func nodeHome(name string) (vector, error) {
+	if len(name) != 3 {
+		return vector{}, fmt.Errorf("unexpected node name: %s", name)
+	}
+
+	// 1 文字目は Y (D M U)、2 文字目は Z (B M F)、3 文字目は X (L M R) の位置
+	var home vector
+	for i, letters := range [3]string{"DMU", "BMF", "LMR"} {
+		j := strings.IndexByte(letters, name[i])
+		if j < 0 {
+			return vector{}, fmt.Errorf("unexpected node name: %s", name)
+		}
+		home[(i+1)%3] = float64(j - 1)
+	}
+	return home, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStateOf(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		alg  string
+		// ファセット番号ごとの、そこにあるステッカーの完成状態での面
+		want string
+	}{
+		{
+			name: "solved",
+			alg:  "",
+			want: "UUUUUUUUU RRRRRRRRR FFFFFFFFF DDDDDDDDD LLLLLLLLL BBBBBBBBB",
+		},
+		{
+			name: "R",
+			alg:  "R",
+			want: "UUFUUFUUF RRRRRRRRR FFDFFDFFD DDBDDBDDB LLLLLLLLL UBBUBBUBB",
+		},
+		{
+			name: "U",
+			alg:  "U",
+			want: "UUUUUUUUU BBBRRRRRR RRRFFFFFF DDDDDDDDD FFFLLLLLL LLLBBBBBB",
+		},
+		{
+			name: "sexy move x6",
+			alg:  strings.Repeat("R U R' U' ", 6),
+			want: "UUUUUUUUU RRRRRRRRR FFFFFFFFF DDDDDDDDD LLLLLLLLL BBBBBBBBB",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := applyAlg(gltfDoc.Nodes, strings.Fields(tt.alg))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			state, err := stateOf(nodes)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []byte
+			for i, s := range state {
+				if i > 0 && i%9 == 0 {
+					got = append(got, ' ')
+				}
+				got = append(got, faceNames[stickers[s].Home/9][0])
+			}
+			if string(got) != tt.want {
+				t.Errorf("state must be %s, actual: %s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+// parseAlgGroups 回転記号から括弧を取り除き、ステップごとの終わりの手数を取得する。
+// 括弧で囲んだ手はまとめて 1 ステップ、括弧の外の手は 1 手ずつ 1 ステップになる
+func parseAlgGroups(algorithm []string) ([]string, []int, error) {
+	moves := make([]string, len(algorithm))
+	var ends []int
+	inGroup := false
+	for i, a := range algorithm {
+		if strings.HasPrefix(a, "(") {
+			if inGroup {
+				return nil, nil, errors.New("brackets of alg must not be nested")
+			}
+			inGroup = true
+			a = a[1:]
+		}
+
+		closed := !inGroup
+		if strings.HasSuffix(a, ")") {
+			if !inGroup {
+				return nil, nil, errors.New("brackets of alg must be balanced")
+			}
+			inGroup = false
+			closed = true
+			a = a[:len(a)-1]
+		}
+
+		moves[i] = a
+		if closed {
+			ends = append(ends, i+1)
+		}
+	}
+	if inGroup {
+		return nil, nil, errors.New("brackets of alg must be balanced")
+	}
+
+	return moves, ends, nil
+}
+
+// generateCubeSteps ステップごとの状態を 1 つずつシーンにしたキューブを生成する。
+// シーン 0 は完成状態、シーン N は N ステップ目の後の状態で、既定のシーンは最後の状態にする。
+// メッシュとバッファーは共有し、ノードだけをシーンごとに複製する
+func generateCubeSteps(algorithm []string, ends []int, style cubeStyle) ([]byte, error) {
+	var doc gltf.Document
+
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		return nil, err
+	}
+	if err := style.apply(&doc); err != nil {
+		return nil, err
+	}
+
+	scene := &gltf.Scene{Name: "solved"}
+	for i := range doc.Nodes {
+		scene.Nodes = append(scene.Nodes, uint32(i))
+	}
+	doc.Scenes = []*gltf.Scene{scene}
+
+	initial := doc.Nodes
+	if err := explodeNodes(initial, style.Explode); err != nil {
+		return nil, err
+	}
+	start := 0
+	for _, end := range ends {
+		nodes, err := applyAlg(initial, algorithm[:end])
+		if err != nil {
+			return nil, err
+		}
+		if err := explodeNodes(nodes, style.Explode); err != nil {
+			return nil, err
+		}
+
+		scene := &gltf.Scene{Name: strings.Join(algorithm[start:end], " ")}
+		for _, node := range nodes {
+			doc.Nodes = append(doc.Nodes, node)
+			scene.Nodes = append(scene.Nodes, uint32(len(doc.Nodes)-1))
+		}
+		doc.Scenes = append(doc.Scenes, scene)
+		start = end
+	}
+	doc.Scene = gltf.Index(uint32(len(doc.Scenes) - 1))
+
+	style.hide(&doc)
+	lighting, err := style.addLighting(&doc, false)
+	if err != nil {
+		return nil, err
+	}
+	if style.View != nil {
+		if err := addCameraView(&doc, style.View, lighting); err != nil {
+			return nil, err
+		}
+	}
+	if style.Describe != "" {
+		if err := describeAsset(&doc, algorithm, style); err != nil {
+			return nil, err
+		}
+	}
+	return encodeGltf(&doc)
+}
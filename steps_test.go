@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qmuntal/gltf"
+)
+
+func TestParseAlgGroups(t *testing.T) {
+	tests := []struct {
+		alg     string
+		moves   []string
+		ends    []int
+		wantErr bool
+	}{
+		{alg: "R U R' U'", moves: []string{"R", "U", "R'", "U'"}, ends: []int{1, 2, 3, 4}},
+		{alg: "(R U R' U') F (R2)", moves: []string{"R", "U", "R'", "U'", "F", "R2"}, ends: []int{4, 5, 6}},
+		{alg: "F (R U) (R' U') F'", moves: []string{"F", "R", "U", "R'", "U'", "F'"}, ends: []int{1, 3, 5, 6}},
+		{alg: "(R U", wantErr: true},
+		{alg: "R U)", wantErr: true},
+		{alg: "(R (U) R')", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			moves, ends, err := parseAlgGroups(strings.Split(tt.alg, " "))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(moves, tt.moves) || !reflect.DeepEqual(ends, tt.ends) {
+				t.Errorf("moves and ends must be %v %v, actual: %v %v", tt.moves, tt.ends, moves, ends)
+			}
+		})
+	}
+}
+
+func TestGenerateCubeSteps(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	alg, ends, err := parseAlgGroups(strings.Split("(R U R' U') F (U2 R')", " "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := generateCubeSteps(alg, ends, cubeStyle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := new(gltf.Document)
+	if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	names := []string{"solved", "R U R' U'", "F", "U2 R'"}
+	if len(doc.Scenes) != len(names) {
+		t.Fatalf("scene count must be %d, actual: %d", len(names), len(doc.Scenes))
+	}
+	if doc.Scene == nil || *doc.Scene != uint32(len(names)-1) {
+		t.Errorf("default scene must be %d, actual: %v", len(names)-1, doc.Scene)
+	}
+
+	for i, scene := range doc.Scenes {
+		if scene.Name != names[i] {
+			t.Errorf("scene %d must be named %q, actual: %q", i, names[i], scene.Name)
+		}
+
+		end := 0
+		if i > 0 {
+			end = ends[i-1]
+		}
+		applied, err := applyAlg(gltfDoc.Nodes, alg[:end])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := nodeRotations(applied)
+		if len(scene.Nodes) != len(want) {
+			t.Fatalf("scene %d must have %d nodes, actual: %d", i, len(want), len(scene.Nodes))
+		}
+		for _, n := range scene.Nodes {
+			node := doc.Nodes[n]
+			r, w := node.RotationOrDefault(), want[node.Name]
+			if d := r[0]*w[0] + r[1]*w[1] + r[2]*w[2] + r[3]*w[3]; math.Abs(math.Abs(d)-1) > 1e-6 {
+				t.Errorf("scene %d: %s rotation must be %v, actual: %v", i, node.Name, w, r)
+			}
+		}
+	}
+}
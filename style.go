@@ -0,0 +1,318 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+const extensionMaterialsVariants = "KHR_materials_variants"
+
+// cubeStyle glTF 形式で出力するキューブの見た目の設定
+type cubeStyle struct {
+	Scheme *[6]color.NRGBA // 面ごとのステッカーの色 (U R F D L B の順)。nil の場合は cube.gltf のまま
+	// Mask 完成状態の位置でマスクするファセット。マスクしたステッカーは本体 (BaseColor) と同じマテリアルにする
+	Mask *[faceletCount]bool
+	// Colors 完成状態の位置ごとのステッカーの色。指定したステッカーはそれぞれ別のマテリアルにする
+	Colors []color.NRGBA
+	// Variants KHR_materials_variants でビューアーが切り替えられる配色
+	Variants []schemeVariant
+	// Highlight 本体を highlightColor で発光させて強調するピースのノード名
+	Highlight map[string]bool
+	// Arrows 回転記号によるピースの移動とねじれを矢印で示す。generateCube のみ
+	Arrows bool
+	// Mirrors 見えない面のステッカーを映す距離 (キューブの 1 辺に対する割合)。0 の場合は映さない。generateCube のみ
+	Mirrors float64
+	// Explode ピースを現在の位置の向きに外側へ離す距離 (ピース 1 個の幅に対する割合)。0 の場合は離さない
+	Explode float64
+	// Hide シーンから除くピースのノード名
+	Hide map[string]bool
+	// XRay 本体を半透明にし、裏側のステッカーも両面を描画する
+	XRay bool
+	// Labels 完成状態のファセット番号ごとにステッカーに載せる文字。0 は文字なし
+	Labels *[faceletCount]byte
+	// Image ステッカーに貼る画像
+	Image *stickerImage
+	// Symbols 各ステッカーに面ごとの記号を載せる
+	Symbols bool
+	// PBR KHR_materials_unlit を使わず、ライトで陰影が付くプラスチックのマテリアルにする
+	PBR bool
+	// Lights KHR_lights_punctual のライトを加える。PBR の場合のみ
+	Lights bool
+	// Ground キューブの下に影を受ける地面を置く。PBR の場合のみ
+	Ground bool
+	// View カメラと、キューブを視点の向きに回すルートノードを加える。nil の場合は加えない
+	View *cubeView
+	// Describe asset.extras.description に入れる説明文の言語。空文字列の場合は入れない
+	Describe string
+}
+
+// xrayOpacity xray の本体の不透明度
+const xrayOpacity = 0.2
+
+// schemeVariant KHR_materials_variants の 1 つの配色
+type schemeVariant struct {
+	Name   string
+	Scheme [6]color.NRGBA
+}
+
+// materialsVariants KHR_materials_variants のルートの拡張
+type materialsVariants struct {
+	Variants []variantName `json:"variants"`
+}
+
+type variantName struct {
+	Name string `json:"name"`
+}
+
+// materialsVariantsMappings KHR_materials_variants のプリミティブの拡張
+type materialsVariantsMappings struct {
+	Mappings []variantMapping `json:"mappings"`
+}
+
+type variantMapping struct {
+	Material uint32 `json:"material"`
+	Variants []int  `json:"variants"`
+}
+
+// apply 複製した glTF のマテリアルを設定に合わせて書き換える
+func (s cubeStyle) apply(doc *gltf.Document) error {
+	if s.Scheme != nil {
+		for _, sticker := range stickers {
+			setMaterialColor(doc.Materials[sticker.Material], s.Scheme[sticker.Home/9])
+		}
+	}
+
+	// マスクしたステッカーも本体と同じマテリアルになるため、マスクより先に本体を書き換える
+	if len(s.Highlight) > 0 {
+		if base, ok := baseMaterialIndex(doc); ok {
+			m := copyMaterial(doc.Materials[base])
+			m.Name = "Highlight"
+			setMaterialColor(m, highlightColor)
+			m.EmissiveFactor = [3]float64{
+				srgbToLinear(highlightColor.R), srgbToLinear(highlightColor.G), srgbToLinear(highlightColor.B),
+			}
+			doc.Materials = append(doc.Materials, m)
+			highlight := uint32(len(doc.Materials) - 1)
+
+			for _, node := range doc.Nodes {
+				if !s.Highlight[node.Name] || node.Mesh == nil {
+					continue
+				}
+				for _, p := range doc.Meshes[*node.Mesh].Primitives {
+					if p.Material != nil && *p.Material == base {
+						p.Material = gltf.Index(highlight)
+					}
+				}
+			}
+		}
+	}
+
+	if s.Mask != nil {
+		if base, ok := baseMaterialIndex(doc); ok {
+			for _, sticker := range stickers {
+				if s.Mask[sticker.Home] {
+					stickerPrimitive(doc, sticker).Material = gltf.Index(base)
+				}
+			}
+		}
+	}
+
+	for _, sticker := range stickers {
+		if sticker.Home >= len(s.Colors) {
+			continue
+		}
+		m := copyMaterial(doc.Materials[sticker.Material])
+		m.Name = faceletName(sticker.Home)
+		setMaterialColor(m, s.Colors[sticker.Home])
+		doc.Materials = append(doc.Materials, m)
+		stickerPrimitive(doc, sticker).Material = gltf.Index(uint32(len(doc.Materials) - 1))
+	}
+
+	if s.Image != nil {
+		if err := addStickerImage(doc, s.Image); err != nil {
+			return err
+		}
+	}
+
+	// マスクしたステッカーと色・画像を指定したステッカーは配色を切り替えない
+	if len(s.Variants) > 0 {
+		addSchemeVariants(doc, s.Variants)
+	}
+
+	if s.Labels != nil {
+		if err := addLabels(doc, s.Labels); err != nil {
+			return err
+		}
+	}
+
+	if s.Symbols {
+		if err := addSymbols(doc, s.Labels != nil); err != nil {
+			return err
+		}
+	}
+
+	if s.XRay {
+		if base, ok := baseMaterialIndex(doc); ok {
+			c := materialColor(doc, base)
+			c.A = uint8(math.Round(xrayOpacity * 0xff))
+			setMaterialColor(doc.Materials[base], c)
+		}
+		for _, m := range doc.Materials {
+			m.DoubleSided = true
+		}
+	}
+
+	// 文字や記号、画像などで追加したマテリアルも含めて書き換える
+	if s.PBR {
+		applyPBR(doc)
+	}
+
+	return nil
+}
+
+// hide 隠すピースのノードを、すべてのシーンのルートノードから除く。
+// アニメーションのチャンネルがノードのインデックスを参照するため、doc.Nodes からは除かない
+func (s cubeStyle) hide(doc *gltf.Document) {
+	if len(s.Hide) == 0 {
+		return
+	}
+	for _, scene := range doc.Scenes {
+		nodes := make([]uint32, 0, len(scene.Nodes))
+		for _, i := range scene.Nodes {
+			if !s.Hide[doc.Nodes[i].Name] {
+				nodes = append(nodes, i)
+			}
+		}
+		scene.Nodes = nodes
+	}
+}
+
+// copyMaterial 色を書き換えるためにマテリアルを複製する。拡張は元のマテリアルと共有する
+func copyMaterial(material *gltf.Material) *gltf.Material {
+	m := *material
+	if m.PBRMetallicRoughness != nil {
+		pbr := *m.PBRMetallicRoughness
+		m.PBRMetallicRoughness = &pbr
+	}
+	return &m
+}
+
+// faceletName ファセット番号を VisualCube の arw と同じ U0 - B8 の名前にする
+func faceletName(facelet int) string {
+	return fmt.Sprintf("%s%d", faceNames[facelet/9], facelet%9)
+}
+
+// stickerPrimitive 複製した glTF からステッカーのプリミティブを取得する。
+// cube.gltf ではノードごとに別のメッシュを持つため、書き換えは他のステッカーに影響しない
+func stickerPrimitive(doc *gltf.Document, sticker Sticker) *gltf.Primitive {
+	for _, node := range doc.Nodes {
+		if node.Name == sticker.Node && node.Mesh != nil {
+			return doc.Meshes[*node.Mesh].Primitives[sticker.Primitive]
+		}
+	}
+	return nil
+}
+
+// addSchemeVariants 配色ごとにステッカーのマテリアルを複製し、KHR_materials_variants で切り替えられるようにする。
+// 既定のマテリアルと同じ色の面はマテリアルを複製せず、対応付けも省略する
+func addSchemeVariants(doc *gltf.Document, variants []schemeVariant) {
+	var faceMaterials [6]uint32
+	for _, sticker := range stickers {
+		faceMaterials[sticker.Home/9] = sticker.Material
+	}
+
+	// variantMaterials[i][f] 配色 i の面 f のマテリアル
+	variantMaterials := make([][6]uint32, len(variants))
+	root := materialsVariants{}
+	for i, v := range variants {
+		root.Variants = append(root.Variants, variantName{Name: v.Name})
+		for f, original := range faceMaterials {
+			if materialColor(doc, original) == v.Scheme[f] {
+				variantMaterials[i][f] = original
+				continue
+			}
+
+			m := copyMaterial(doc.Materials[original])
+			m.Name = fmt.Sprintf("%s %s", v.Name, faceNames[f])
+			setMaterialColor(m, v.Scheme[f])
+			doc.Materials = append(doc.Materials, m)
+			variantMaterials[i][f] = uint32(len(doc.Materials) - 1)
+		}
+	}
+
+	for _, mesh := range doc.Meshes {
+		for _, p := range mesh.Primitives {
+			if p.Material == nil {
+				continue
+			}
+			face := -1
+			for f, m := range faceMaterials {
+				if m == *p.Material {
+					face = f
+				}
+			}
+			if face < 0 {
+				continue
+			}
+
+			var mappings materialsVariantsMappings
+			for i := range variants {
+				material := variantMaterials[i][face]
+				if material == *p.Material {
+					continue
+				}
+				found := false
+				for j := range mappings.Mappings {
+					if mappings.Mappings[j].Material == material {
+						mappings.Mappings[j].Variants = append(mappings.Mappings[j].Variants, i)
+						found = true
+					}
+				}
+				if !found {
+					mappings.Mappings = append(mappings.Mappings, variantMapping{Material: material, Variants: []int{i}})
+				}
+			}
+			if len(mappings.Mappings) == 0 {
+				continue
+			}
+			if p.Extensions == nil {
+				p.Extensions = make(gltf.Extensions)
+			}
+			p.Extensions[extensionMaterialsVariants] = mappings
+		}
+	}
+
+	if doc.Extensions == nil {
+		doc.Extensions = make(gltf.Extensions)
+	}
+	doc.Extensions[extensionMaterialsVariants] = root
+	doc.ExtensionsUsed = append(doc.ExtensionsUsed, extensionMaterialsVariants)
+}
+
+// schemeVariants KHR_materials_variants に含める配色を取得する。
+// 配色の名前以外の sch を指定した場合は、その配色を custom として先頭に加える
+func schemeVariants(sch string) ([]schemeVariant, error) {
+	var variants []schemeVariant
+	if sch != "" && !isSchemePreset(sch) {
+		scheme, err := parseCubeScheme(sch)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, schemeVariant{Name: "custom", Scheme: scheme})
+	}
+
+	for _, name := range schemePresets {
+		scheme, err := parseCubeScheme(name)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, schemeVariant{Name: name, Scheme: scheme})
+	}
+	for _, name := range palettePresets {
+		variants = append(variants, schemeVariant{Name: name, Scheme: palettes[name]})
+	}
+	return variants, nil
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"testing"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+func TestParseCubeScheme(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+	western := defaultScheme()
+
+	japanese, err := parseCubeScheme("japanese")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 白・緑・赤の面は western のままで、白の反対が青、緑の反対が黄になる
+	faces := func(scheme [6]color.NRGBA) map[string]vector {
+		normals := make(map[string]vector)
+		for _, name := range []string{"White", "Yellow", "Red", "Orange", "Blue", "Green"} {
+			f, ok := westernFace(name)
+			if !ok {
+				t.Fatalf("cube.gltf must have %s stickers", name)
+			}
+			for i, c := range scheme {
+				if c == western[f] {
+					normals[name] = faceAxes[i].normal
+				}
+			}
+		}
+		return normals
+	}
+	w, j := faces(western), faces(japanese)
+	for _, name := range []string{"White", "Green", "Red", "Orange"} {
+		if j[name] != w[name] {
+			t.Errorf("japanese %s must stay at %v, actual: %v", name, w[name], j[name])
+		}
+	}
+	if j["Blue"] != scaled(j["White"], -1) || j["Yellow"] != scaled(j["Green"], -1) {
+		t.Errorf("japanese must have blue opposite white and yellow opposite green: %v", j)
+	}
+	// 白・緑・赤の角は、どちらの配色でも白を上、緑を前にしたときに赤が右になる
+	for name, normals := range map[string]map[string]vector{"western": w, "japanese": j} {
+		if d := dot(cross(normals["White"], normals["Green"]), normals["Red"]); d != 1 {
+			t.Errorf("%s must have red on the right of white and green, actual: %g", name, d)
+		}
+	}
+
+	custom, err := parseCubeScheme("#ff0000,y,green,w,o,b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if custom[FaceU] != (color.NRGBA{R: 0xff, A: 0xff}) || custom[FaceB] != colorCodes['b'] {
+		t.Errorf("unexpected custom scheme: %v", custom)
+	}
+
+	if _, err := parseCubeScheme("eastern"); err == nil {
+		t.Error("unknown scheme must be an error")
+	}
+}
+
+func TestParsePalette(t *testing.T) {
+	for _, name := range palettePresets {
+		palette, err := parsePalette(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range palette {
+			for j := i + 1; j < len(palette); j++ {
+				if palette[i] == palette[j] {
+					t.Errorf("%s: %s and %s must have different colors", name, faceNames[i], faceNames[j])
+				}
+			}
+		}
+
+		japanese, err := parseSchemeOn(palette, schemeJapanese)
+		if err != nil {
+			t.Fatal(err)
+		}
+		yellow, _ := westernFace("Yellow")
+		blue, _ := westernFace("Blue")
+		if japanese[yellow] != palette[blue] || japanese[blue] != palette[yellow] {
+			t.Errorf("%s: japanese must swap yellow and blue of the palette: %v", name, japanese)
+		}
+	}
+
+	if _, err := parsePalette("achromatopsia"); err == nil {
+		t.Error("unknown palette must be an error")
+	}
+}
+
+func TestCubeStyleScheme(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	scheme, err := parseCubeScheme("japanese")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := generateCube(nil, cubeStyle{Scheme: &scheme})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := new(gltf.Document)
+	if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range stickers {
+		if got, want := materialColor(doc, s.Material), scheme[s.Home/9]; got != want {
+			t.Errorf("sticker %d must be %v, actual: %v", s.Home, want, got)
+		}
+	}
+	if got, want := materialColor(gltfDoc, stickers[0].Material), defaultScheme()[stickers[0].Home/9]; got != want {
+		t.Errorf("original document must not change: %v, actual: %v", want, got)
+	}
+}
+
+func TestAddSchemeVariants(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	variants, err := schemeVariants("rrrrrr")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != len(schemePresets)+len(palettePresets)+1 || variants[0].Name != "custom" {
+		t.Fatalf("custom sch must be the first variant: %v", variants)
+	}
+
+	var doc gltf.Document
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		t.Fatal(err)
+	}
+	if err := (cubeStyle{Variants: variants}).apply(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range stickers {
+		p := stickerPrimitive(&doc, s)
+		mappings, _ := p.Extensions[extensionMaterialsVariants].(materialsVariantsMappings)
+		for i, v := range variants {
+			material := *p.Material
+			for _, m := range mappings.Mappings {
+				for _, j := range m.Variants {
+					if j == i {
+						material = m.Material
+					}
+				}
+			}
+			if got, want := materialColor(&doc, material), v.Scheme[s.Home/9]; got != want {
+				t.Errorf("%s sticker %d must be %v, actual: %v", v.Name, s.Home, want, got)
+			}
+		}
+	}
+}
+
+func TestCubeStyleMask(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	mask, err := stageMask("oll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	variants, err := schemeVariants("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc gltf.Document
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		t.Fatal(err)
+	}
+	if err := (cubeStyle{Mask: &mask, Variants: variants}).apply(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := baseMaterialIndex(&doc)
+	for _, s := range stickers {
+		p := stickerPrimitive(&doc, s)
+		if masked := *p.Material == base; masked != mask[s.Home] {
+			t.Errorf("sticker %d: masked must be %v, actual: %v", s.Home, mask[s.Home], masked)
+		}
+		if _, ok := p.Extensions[extensionMaterialsVariants]; ok && mask[s.Home] {
+			t.Errorf("masked sticker %d must not have variants", s.Home)
+		}
+	}
+}
+
+func TestCubeStyleColors(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	mask, err := stageMask("oll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	colors, err := parseFaceletColors("ttrrr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc gltf.Document
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		t.Fatal(err)
+	}
+	if err := (cubeStyle{Mask: &mask, Colors: colors}).apply(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	materials := make(map[uint32]bool)
+	for _, s := range stickers {
+		if s.Home >= len(colors) {
+			continue
+		}
+		p := stickerPrimitive(&doc, s)
+		if materials[*p.Material] {
+			t.Errorf("sticker %d must have its own material", s.Home)
+		}
+		materials[*p.Material] = true
+
+		m := doc.Materials[*p.Material]
+		if m.Name != faceletName(s.Home) {
+			t.Errorf("sticker %d: material name = %s", s.Home, m.Name)
+		}
+		if got := materialColor(&doc, *p.Material); got != colors[s.Home] {
+			t.Errorf("sticker %d must be %v, actual: %v", s.Home, colors[s.Home], got)
+		}
+		if transparent := m.AlphaMode == gltf.AlphaBlend; transparent != (colors[s.Home].A == 0) {
+			t.Errorf("sticker %d: alphaMode = %v", s.Home, m.AlphaMode)
+		}
+	}
+}
+
+func TestCubeStyleHighlight(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	mask, err := stageMask("oll")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc gltf.Document
+	if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+		t.Fatal(err)
+	}
+	if err := (cubeStyle{Mask: &mask, Highlight: map[string]bool{"UFR": true}}).apply(&doc); err != nil {
+		t.Fatal(err)
+	}
+
+	base, _ := baseMaterialIndex(&doc)
+	for _, node := range doc.Nodes {
+		for j, p := range doc.Meshes[*node.Mesh].Primitives {
+			original := *gltfDoc.Meshes[*node.Mesh].Primitives[j].Material
+			highlighted := doc.Materials[*p.Material].Name == "Highlight"
+			if want := node.Name == "UFR" && original == base; highlighted != want {
+				t.Errorf("%s primitive %d: highlighted must be %v, actual: %v", node.Name, j, want, highlighted)
+			}
+		}
+	}
+	for _, s := range stickers {
+		if p := stickerPrimitive(&doc, s); s.Node == "UFR" && mask[s.Home] && *p.Material != base {
+			t.Errorf("masked sticker %d of UFR must not be highlighted", s.Home)
+		}
+	}
+}
+
+func TestCubeStyleHide(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests, err := parseHighlight("centers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := highlightNodes(nil, tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	style := cubeStyle{Hide: hidden, XRay: true}
+
+	data, err := generateCubeSteps([]string{"R", "U"}, []int{1, 2}, style)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := new(gltf.Document)
+	if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, scene := range doc.Scenes {
+		if len(scene.Nodes) != 26-6 {
+			t.Errorf("scene %s must have %d nodes, actual: %d", scene.Name, 26-6, len(scene.Nodes))
+		}
+		for _, i := range scene.Nodes {
+			if hidden[doc.Nodes[i].Name] {
+				t.Errorf("scene %s must not have %s", scene.Name, doc.Nodes[i].Name)
+			}
+		}
+	}
+
+	base, _ := baseMaterialIndex(doc)
+	if m := doc.Materials[base]; m.AlphaMode != gltf.AlphaBlend || materialColor(doc, base).A == 0xff {
+		t.Error("body must be translucent with xray")
+	}
+	for _, m := range doc.Materials {
+		if !m.DoubleSided {
+			t.Errorf("%s must be double sided with xray", m.Name)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	// symbolRadius ステッカーの幅に対する記号の半径
+	symbolRadius = 0.3
+	// symbolCornerRadius 文字と一緒に載せる場合に、ステッカーの左上に小さく置く記号の半径
+	symbolCornerRadius = 0.09
+	// symbolWidth 記号の半径に対する線の幅
+	symbolWidth = 0.2
+)
+
+// symbolColor ステッカーに載せる記号の色
+var symbolColor = colorCodes['k']
+
+// faceSymbols 完成状態の面ごとの記号の折れ線。半径 1 の円に収まる座標で、Y は上向き
+var faceSymbols = [6][][]point{
+	FaceU: {circlePoints(16)},
+	FaceR: {{{-0.8, -0.8}, {0.8, 0.8}}, {{-0.8, 0.8}, {0.8, -0.8}}},
+	FaceF: {{{0, 1}, {0.87, -0.5}, {-0.87, -0.5}, {0, 1}}},
+	FaceD: {{{-0.75, -0.75}, {0.75, -0.75}, {0.75, 0.75}, {-0.75, 0.75}, {-0.75, -0.75}}},
+	FaceL: {{{0, -1}, {0, 1}}, {{-1, 0}, {1, 0}}},
+	FaceB: {{{0, 1}, {1, 0}, {0, -1}, {-1, 0}, {0, 1}}},
+}
+
+// circlePoints 半径 1 の円を n 角形の閉じた折れ線にする
+func circlePoints(n int) []point {
+	points := make([]point, n+1)
+	for i := range points {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		points[i] = point{math.Cos(angle), math.Sin(angle)}
+	}
+	return points
+}
+
+// addSymbols 色を見分けにくくても面が分かるように、各ステッカーに完成状態の面ごとの記号を置く。
+// withLabels の場合は文字と重ならないよう、ステッカーの左上に小さく置く
+func addSymbols(doc *gltf.Document, withLabels bool) error {
+	radius, center := symbolRadius, point{}
+	if withLabels {
+		radius, center = symbolCornerRadius, point{-0.33, 0.33}
+	}
+
+	var marks [faceletCount][][]point
+	for facelet := range marks {
+		for _, stroke := range faceSymbols[facelet/9] {
+			line := make([]point, len(stroke))
+			for i, p := range stroke {
+				line[i] = point{center.X + p.X*radius, center.Y + p.Y*radius}
+			}
+			marks[facelet] = append(marks[facelet], line)
+		}
+	}
+	return addStickerMarks(doc, "Symbol", symbolColor, &marks, radius*symbolWidth)
+}
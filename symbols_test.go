@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestAddSymbols(t *testing.T) {
+	for _, withLabels := range []bool{false, true} {
+		doc := copyCubeDocument(t)
+		if err := addSymbols(doc, withLabels); err != nil {
+			t.Fatal(err)
+		}
+
+		// センターを含むすべてのピースに、記号のプリミティブが 1 つ加わる
+		for _, node := range doc.Nodes {
+			primitives := doc.Meshes[*node.Mesh].Primitives
+			if len(primitives) != len(gltfDoc.Meshes[*node.Mesh].Primitives)+1 {
+				t.Errorf("%s must have a symbol primitive", node.Name)
+				continue
+			}
+			if m := doc.Materials[*primitives[len(primitives)-1].Material]; m.Name != "Symbol" {
+				t.Errorf("%s: material of symbol = %s", node.Name, m.Name)
+			}
+		}
+	}
+}
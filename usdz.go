@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	usdaFileName = "cube.usda"
+	// usdzAlignment USDZ ではファイルのデータを 64 バイト境界に揃える必要がある
+	usdzAlignment = 64
+	// usdzPaddingID 位置合わせ用の extra フィールドのヘッダー ID (usdzip と同じ値)
+	usdzPaddingID = 0x1986
+)
+
+// generateUSDZ 回転記号を適用したキューブを、AR Quick Look で使える USDZ にする。
+// メッシュとノードの回転は glTF と同じものを使い、1 辺を defaultCubeSize (mm) に合わせる
+func generateUSDZ(algorithm []string) ([]byte, error) {
+	nodes, err := applyAlg(gltfDoc.Nodes, algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	usda, err := encodeUSDA(gltfDoc, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	return createUSDZ([]zipFile{{Name: usdaFileName, Data: usda}})
+}
+
+// encodeUSDA ノードごとの Xform とプリミティブごとの Mesh を USDA 形式に変換する。
+// 単位は cm (metersPerUnit = 0.01) で、キューブの底面を原点の高さに合わせる
+func encodeUSDA(doc *gltf.Document, nodes []*gltf.Node) ([]byte, error) {
+	primitives, err := worldPrimitives(doc, nodes)
+	if err != nil {
+		return nil, err
+	}
+	min, max := bounds(primitives)
+	k := defaultCubeSize / 10 / math.Max(max[0]-min[0], math.Max(max[1]-min[1], max[2]-min[2]))
+
+	buffer := new(bytes.Buffer)
+	buffer.WriteString("#usda 1.0\n(\n\tdefaultPrim = \"Cube\"\n\tmetersPerUnit = 0.01\n\tupAxis = \"Y\"\n)\n\n")
+	buffer.WriteString("def Xform \"Cube\" (\n\tkind = \"component\"\n)\n{\n")
+	fmt.Fprintf(buffer, "\tdouble3 xformOp:translate = %s\n", usdTuple(-(min[0]+max[0])/2*k, -min[1]*k, -(min[2]+max[2])/2*k))
+	fmt.Fprintf(buffer, "\tfloat3 xformOp:scale = %s\n", usdTuple(k, k, k))
+	buffer.WriteString("\tuniform token[] xformOpOrder = [\"xformOp:translate\", \"xformOp:scale\"]\n\n")
+
+	buffer.WriteString("\tdef Scope \"Materials\"\n\t{\n")
+	for i, m := range doc.Materials {
+		c := *gltf.NewRGBA()
+		if m.PBRMetallicRoughness != nil {
+			c = m.PBRMetallicRoughness.BaseColorFactorOrDefault()
+		}
+
+		name := materialName(doc, uint32(i))
+		fmt.Fprintf(buffer, "\t\tdef Material \"%s\"\n\t\t{\n", name)
+		fmt.Fprintf(buffer, "\t\t\ttoken outputs:surface.connect = </Cube/Materials/%s/Surface.outputs:surface>\n\n", name)
+		buffer.WriteString("\t\t\tdef Shader \"Surface\"\n\t\t\t{\n")
+		buffer.WriteString("\t\t\t\tuniform token info:id = \"UsdPreviewSurface\"\n")
+		fmt.Fprintf(buffer, "\t\t\t\tcolor3f inputs:diffuseColor = %s\n", usdTuple(c.R, c.G, c.B))
+		fmt.Fprintf(buffer, "\t\t\t\tfloat inputs:opacity = %s\n", usdFloat(c.A))
+		buffer.WriteString("\t\t\t\tfloat inputs:roughness = 0.5\n")
+		buffer.WriteString("\t\t\t\ttoken outputs:surface\n\t\t\t}\n\t\t}\n")
+	}
+	buffer.WriteString("\t}\n")
+
+	for _, node := range nodes {
+		if node.Mesh == nil {
+			continue
+		}
+
+		s, r, t := node.ScaleOrDefault(), node.RotationOrDefault(), node.TranslationOrDefault()
+		fmt.Fprintf(buffer, "\n\tdef Xform \"%s\"\n\t{\n", node.Name)
+		fmt.Fprintf(buffer, "\t\tdouble3 xformOp:translate = %s\n", usdTuple(t[0], t[1], t[2]))
+		// glTF は (x, y, z, w)、USD は (w, x, y, z) の順
+		fmt.Fprintf(buffer, "\t\tquatf xformOp:orient = %s\n", usdTuple(r[3], r[0], r[1], r[2]))
+		fmt.Fprintf(buffer, "\t\tfloat3 xformOp:scale = %s\n", usdTuple(s[0], s[1], s[2]))
+		buffer.WriteString("\t\tuniform token[] xformOpOrder = [\"xformOp:translate\", \"xformOp:orient\", \"xformOp:scale\"]\n")
+
+		local, err := meshPrimitives(doc, node)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range local {
+			counts := make([]string, len(p.Indices)/3)
+			for j := range counts {
+				counts[j] = "3"
+			}
+			faces := make([]string, len(p.Indices))
+			for j, index := range p.Indices {
+				faces[j] = strconv.FormatUint(uint64(index), 10)
+			}
+
+			fmt.Fprintf(buffer, "\n\t\tdef Mesh \"Primitive%d\" (\n\t\t\tprepend apiSchemas = [\"MaterialBindingAPI\"]\n\t\t)\n\t\t{\n", p.Primitive)
+			fmt.Fprintf(buffer, "\t\t\tint[] faceVertexCounts = [%s]\n", strings.Join(counts, ", "))
+			fmt.Fprintf(buffer, "\t\t\tint[] faceVertexIndices = [%s]\n", strings.Join(faces, ", "))
+			fmt.Fprintf(buffer, "\t\t\tpoint3f[] points = [%s]\n", usdVectors(p.Positions))
+			fmt.Fprintf(buffer, "\t\t\tnormal3f[] normals = [%s] (\n\t\t\t\tinterpolation = \"vertex\"\n\t\t\t)\n", usdVectors(p.Normals))
+			buffer.WriteString("\t\t\tuniform token subdivisionScheme = \"none\"\n")
+			fmt.Fprintf(buffer, "\t\t\trel material:binding = </Cube/Materials/%s>\n\t\t}\n", materialName(doc, p.Material))
+		}
+		buffer.WriteString("\t}\n")
+	}
+	buffer.WriteString("}\n")
+
+	return buffer.Bytes(), nil
+}
+
+// usdFloat 数値を USDA の浮動小数点数の文字列に変換する
+func usdFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', 7, 64)
+}
+
+// usdTuple 数値を USDA のタプル (a, b, ...) の文字列に変換する
+func usdTuple(values ...float64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = usdFloat(v)
+	}
+	return "(" + strings.Join(s, ", ") + ")"
+}
+
+// usdVectors ベクトルを USDA のタプルの配列の中身に変換する
+func usdVectors(vectors []vector) string {
+	s := make([]string, len(vectors))
+	for i, v := range vectors {
+		s[i] = usdTuple(v[0], v[1], v[2])
+	}
+	return strings.Join(s, ", ")
+}
+
+// createUSDZ ファイルを無圧縮の zip にまとめる。
+// 各ファイルのデータが 64 バイト境界から始まるように、ローカルヘッダーの extra フィールドで位置を合わせる
+func createUSDZ(files []zipFile) ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	central := new(bytes.Buffer)
+
+	modTime, modDate := msDosTime(time.Now())
+	for _, f := range files {
+		if uint64(len(f.Data)) > 0xffffffff {
+			return nil, fmt.Errorf("file is too large for zip: %s", f.Name)
+		}
+
+		offset := buffer.Len()
+		padding := (usdzAlignment - (offset+30+len(f.Name))%usdzAlignment) % usdzAlignment
+		if padding > 0 && padding < 4 {
+			padding += usdzAlignment
+		}
+		var extra []byte
+		if padding > 0 {
+			extra = make([]byte, padding)
+			binary.LittleEndian.PutUint16(extra, usdzPaddingID)
+			binary.LittleEndian.PutUint16(extra[2:], uint16(padding-4))
+		}
+
+		crc := crc32.ChecksumIEEE(f.Data)
+		size := uint32(len(f.Data))
+
+		// ローカルファイルヘッダー
+		writeLittleEndian(buffer,
+			uint32(0x04034b50), uint16(20), uint16(0), uint16(0), modTime, modDate,
+			crc, size, size, uint16(len(f.Name)), uint16(len(extra)),
+		)
+		buffer.WriteString(f.Name)
+		buffer.Write(extra)
+		buffer.Write(f.Data)
+
+		// セントラルディレクトリ
+		writeLittleEndian(central,
+			uint32(0x02014b50), uint16(20), uint16(20), uint16(0), uint16(0), modTime, modDate,
+			crc, size, size, uint16(len(f.Name)), uint16(0), uint16(0), uint16(0), uint16(0), uint32(0), uint32(offset),
+		)
+		central.WriteString(f.Name)
+	}
+
+	directoryOffset := buffer.Len()
+	buffer.Write(central.Bytes())
+
+	// セントラルディレクトリの終端
+	writeLittleEndian(buffer,
+		uint32(0x06054b50), uint16(0), uint16(0), uint16(len(files)), uint16(len(files)),
+		uint32(central.Len()), uint32(directoryOffset), uint16(0),
+	)
+
+	return buffer.Bytes(), nil
+}
+
+// msDosTime 時刻を zip で使う MS-DOS 形式の時刻と日付に変換する
+func msDosTime(t time.Time) (uint16, uint16) {
+	return uint16(t.Hour()<<11 | t.Minute()<<5 | t.Second()>>1),
+		uint16((t.Year()-1980)<<9 | int(t.Month())<<5 | t.Day())
+}
+
+// writeLittleEndian 固定長の値をリトルエンディアンで順に書き込む
+func writeLittleEndian(buffer *bytes.Buffer, values ...interface{}) {
+	for _, v := range values {
+		_ = binary.Write(buffer, binary.LittleEndian, v)
+	}
+}
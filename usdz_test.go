@@ -0,0 +1,55 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCreateUSDZ(t *testing.T) {
+	files := []zipFile{
+		{Name: "a.usda", Data: []byte("#usda 1.0\n")},
+		{Name: "textures/b.png", Data: bytes.Repeat([]byte{1}, 100)},
+		{Name: "c", Data: []byte("c")},
+	}
+
+	data, err := createUSDZ(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(files) {
+		t.Fatalf("usdz must have %d files, actual: %d", len(files), len(r.File))
+	}
+
+	for i, f := range r.File {
+		if f.Name != files[i].Name || f.Method != zip.Store {
+			t.Errorf("file %d must be %s stored, actual: %s (method %d)", i, files[i].Name, f.Name, f.Method)
+		}
+
+		offset, err := f.DataOffset()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset%usdzAlignment != 0 {
+			t.Errorf("%s must start at a multiple of %d, actual: %d", f.Name, usdzAlignment, offset)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, files[i].Data) {
+			t.Errorf("%s data mismatch", f.Name)
+		}
+	}
+}
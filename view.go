@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/qmuntal/gltf"
+)
+
+const (
+	viewFront   = "front"
+	viewOblique = "oblique"
+	viewTop     = "top"
+	viewBottom  = "bottom"
+	viewCustom  = "custom"
+)
+
+// viewRotations 視点の名前ごとの、VisualCube の r パラメーターと同じ形式の回転
+var viewRotations = map[string]string{
+	viewFront:   "",
+	viewOblique: visualCubeDefaultRotation,
+	viewTop:     "x-90",
+	viewBottom:  "x90",
+}
+
+// cubeView glTF に加えるカメラの視点
+type cubeView struct {
+	Name     string
+	Rotation matrix // キューブに適用する回転。カメラは +Z 側から原点を向く
+}
+
+// parseCubeView view パラメーターを視点に変換する。custom の場合は rotation (r パラメーター) を使う
+func parseCubeView(name, rotation string) (*cubeView, error) {
+	if name == viewCustom {
+		if rotation == "" {
+			return nil, errors.New(`r is required with view "custom"`)
+		}
+	} else {
+		r, ok := viewRotations[name]
+		if !ok {
+			return nil, fmt.Errorf(`view must be one of "front oblique top bottom custom": %s`, name)
+		}
+		if rotation != "" {
+			return nil, errors.New(`r is only available with view "custom"`)
+		}
+		rotation = r
+	}
+
+	m, err := parseVisualCubeRotation(rotation)
+	if err != nil {
+		return nil, err
+	}
+	return &cubeView{Name: name, Rotation: m}, nil
+}
+
+// addCameraView シーンごとに、視点の回転を適用したルートノード (Cube) の下にピース・矢印・鏡のノードをまとめ、
+// 透視投影のカメラのノード (Camera) を png と同じ距離と画角で +Z 側に置く。
+// カメラを使わないビューアーでも、正面 (+Z 側) から見ると視点の向きになる。
+// lighting (addLighting で加えたライトと地面のノード) は回さずにシーンのルートに残す
+func addCameraView(doc *gltf.Document, view *cubeView, lighting map[uint32]bool) error {
+	extent, err := solvedExtent()
+	if err != nil {
+		return err
+	}
+
+	doc.Cameras = append(doc.Cameras, &gltf.Camera{
+		Name: view.Name,
+		Perspective: &gltf.Perspective{
+			AspectRatio: gltf.Float64(1),
+			// png の viewBox の端が、原点での半径 visualCubeViewBox / visualCubeScale に当たる
+			Yfov:  2 * math.Atan(-visualCubeViewBox[0]/visualCubeScale/visualCubeDistance),
+			Znear: extent * 0.1,
+			Zfar:  gltf.Float64(extent * visualCubeDistance * 4),
+		},
+	})
+	camera := uint32(len(doc.Cameras) - 1)
+
+	for _, scene := range doc.Scenes {
+		var children, roots []uint32
+		for _, i := range scene.Nodes {
+			if lighting[i] {
+				roots = append(roots, i)
+			} else {
+				children = append(children, i)
+			}
+		}
+
+		doc.Nodes = append(doc.Nodes, &gltf.Node{
+			Name:     "Cube",
+			Rotation: matrixQuaternion(view.Rotation),
+			Children: children,
+		})
+		root := uint32(len(doc.Nodes) - 1)
+
+		doc.Nodes = append(doc.Nodes, &gltf.Node{
+			Name:        "Camera",
+			Camera:      gltf.Index(camera),
+			Translation: [3]float64{0, 0, extent * visualCubeDistance},
+		})
+		scene.Nodes = append([]uint32{root, uint32(len(doc.Nodes) - 1)}, roots...)
+	}
+	return nil
+}
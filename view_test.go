@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/getlantern/deepcopy"
+	"github.com/qmuntal/gltf"
+)
+
+func TestMatrixQuaternion(t *testing.T) {
+	for _, r := range []string{"", "y45x-34", "x-90", "x90", "y180", "z180x30", "x180y-20"} {
+		m, err := parseVisualCubeRotation(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := matrixQuaternion(m)
+		for _, v := range []vector{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}} {
+			want, got := m.apply(v), rotateVector(q, v)
+			for i := range want {
+				if math.Abs(want[i]-got[i]) > 1e-9 {
+					t.Errorf("%s: %v must be rotated to %v: %v", r, v, want, got)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestParseCubeView(t *testing.T) {
+	tests := []struct {
+		name, rotation string
+		valid          bool
+	}{
+		{name: viewFront, valid: true},
+		{name: viewOblique, valid: true},
+		{name: viewCustom, rotation: "y30x-20", valid: true},
+		{name: viewCustom},
+		{name: viewCustom, rotation: "w30"},
+		{name: viewTop, rotation: "y30"},
+		{name: "side"},
+	}
+	for _, tt := range tests {
+		_, err := parseCubeView(tt.name, tt.rotation)
+		if (err == nil) != tt.valid {
+			t.Errorf("view=%s&r=%s: error = %v", tt.name, tt.rotation, err)
+		}
+	}
+}
+
+func TestAddCameraView(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 視点ごとに、カメラ (+Z 側) の方を向く完成状態の面
+	tests := map[string]Face{viewFront: FaceF, viewTop: FaceU, viewBottom: FaceD}
+	for name, face := range tests {
+		view, err := parseCubeView(name, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var doc gltf.Document
+		if err := deepcopy.Copy(&doc, gltfDoc); err != nil {
+			t.Fatal(err)
+		}
+		// 名前が Ground でも、addLighting で加えていないノードはキューブと一緒に回す
+		doc.Nodes[0].Name = "Ground"
+		if err := addCameraView(&doc, view, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		scene := doc.Scenes[0].Nodes
+		if len(scene) != 2 {
+			t.Fatalf("%s: scene must have the root and the camera: %v", name, scene)
+		}
+		root, camera := doc.Nodes[scene[0]], doc.Nodes[scene[1]]
+		if len(root.Children) != len(gltfDoc.Nodes) {
+			t.Errorf("%s: root must have all pieces: %d", name, len(root.Children))
+		}
+		if camera.Camera == nil || doc.Cameras[*camera.Camera].Perspective == nil {
+			t.Errorf("%s: camera node must have a perspective camera", name)
+		}
+		if normal := rotateVector(root.RotationOrDefault(), faceAxes[face].normal); math.Abs(normal[2]-1) > 1e-9 {
+			t.Errorf("%s: %s must face the camera: %v", name, faceNames[face], normal)
+		}
+	}
+}
+
+func TestAddCameraViewGround(t *testing.T) {
+	if err := initCube(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{viewFront, viewOblique, viewTop, viewBottom, viewCustom} {
+		rotation := ""
+		if name == viewCustom {
+			rotation = "z30x-50"
+		}
+		view, err := parseCubeView(name, rotation)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, mirrors := range []float64{0, 1} {
+			data, err := generateCube([]string{"R", "U"}, cubeStyle{PBR: true, Lights: true, Ground: true, Mirrors: mirrors, View: view})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var doc gltf.Document
+			if err := gltf.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+
+			// ライトと地面はカメラと同じくシーンのルートに置き、視点の回転を受けない
+			var cube, ground *gltf.Node
+			lights := 0
+			for _, i := range doc.Scenes[0].Nodes {
+				node := doc.Nodes[i]
+				switch {
+				case node.Name == "Cube":
+					cube = node
+				case node.Name == "Ground":
+					ground = node
+				case node.Extensions[extensionLightsPunctual] != nil:
+					lights++
+				}
+			}
+			if cube == nil || ground == nil || lights != len(studioLights) {
+				t.Fatalf("%s: scene must have the cube, the ground and %d lights as roots", name, len(studioLights))
+			}
+			for _, i := range cube.Children {
+				if node := doc.Nodes[i]; node.Name == "Ground" || node.Extensions[extensionLightsPunctual] != nil {
+					t.Errorf("%s: %s must not be rotated with the cube", name, doc.Nodes[i].Name)
+				}
+			}
+
+			groundPrimitives, err := worldPrimitives(&doc, []*gltf.Node{ground})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, groundMax := bounds(groundPrimitives)
+
+			// 鏡の子はピースのノードと同じ変換を使い、親の鏡映を掛けてから視点の回転を受ける
+			var primitives []worldPrimitive
+			for _, i := range cube.Children {
+				node := doc.Nodes[i]
+				if node.Mesh != nil {
+					p, err := worldPrimitives(&doc, []*gltf.Node{node})
+					if err != nil {
+						t.Fatal(err)
+					}
+					primitives = append(primitives, p...)
+					continue
+				}
+				children := make([]*gltf.Node, len(node.Children))
+				for j, c := range node.Children {
+					children[j] = doc.Nodes[c]
+				}
+				p, err := worldPrimitives(&doc, children)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, wp := range p {
+					primitives = append(primitives, wp.transformed(node.ScaleOrDefault(), node.RotationOrDefault(), node.TranslationOrDefault()))
+				}
+			}
+			bottom := math.Inf(1)
+			for _, p := range primitives {
+				for _, v := range p.Positions {
+					bottom = math.Min(bottom, rotateVector(cube.RotationOrDefault(), v)[1])
+				}
+			}
+			if groundMax[1] >= bottom {
+				t.Errorf("%s (mirrors=%g): ground must be under the cube: ground %g, cube %g", name, mirrors, groundMax[1], bottom)
+			}
+		}
+	}
+}
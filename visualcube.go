@@ -0,0 +1,456 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	visualCubeViewNormal = "normal"
+	visualCubeViewPlan   = "plan"
+	visualCubeViewTrans  = "trans"
+)
+
+const (
+	visualCubeDefaultRotation = "y45x-34"
+	visualCubeDefaultSize     = 128
+	visualCubeMaxSize         = 1024
+	// visualCubeDistance 透視投影でのカメラとキューブの中心の距離 (キューブの 1 辺を 1 とする)
+	visualCubeDistance = 5
+	// visualCubeScale 投影した座標を viewBox に収めるための拡大率
+	visualCubeScale = 0.85
+	// planFold 平面図で U 面の周囲に描く側面の広がり具合
+	planFold = 0.6
+	// planScale 平面図の座標を viewBox に収めるための拡大率
+	planScale = 1.45
+	// stickerInset ステッカーの隙間 (ステッカーの幅に対する割合)
+	stickerInset = 0.1
+)
+
+var visualCubeViewBox = viewBox{-0.9, -0.9, 1.8, 1.8}
+
+type visualCubeRequest struct {
+	Algorithm  []string
+	Format     string
+	Definition string        // fd: 完成状態の位置ごとの面 (u r f d l b) または n, t
+	Colors     []color.NRGBA // fc: 完成状態の位置ごとの色
+	Scheme     [6]color.NRGBA
+	View       string
+	Mask       [faceletCount]bool
+	Rotation   matrix
+	Arrows     []arrow
+	Background color.NRGBA
+	Size       int
+}
+
+// arrow ファセットの中心どうしを結ぶ矢印
+type arrow struct {
+	From, To  int
+	Via       int // 曲線の制御に使うファセット。直線の場合は -1
+	Scale     float64
+	Influence float64
+	Color     color.NRGBA
+}
+
+var (
+	visualCubeAlgRegex      = regexp.MustCompile(`[UDFBLR](?:2'|'2|2|')?`)
+	visualCubeRotationRegex = regexp.MustCompile(`([xyz])(-?[0-9]+(?:\.[0-9]+)?)`)
+	arrowFaceletsRegex      = regexp.MustCompile(`^(?:[URFDLB][0-8]){2,3}$`)
+)
+
+// generateVisualCube VisualCube 互換のパラメーターでキューブの画像を生成する
+func generateVisualCube(req *visualCubeRequest) ([]byte, error) {
+	nodes, err := applyAlg(gltfDoc.Nodes, req.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := stateOf(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	var colors [faceletCount]color.NRGBA
+	for i, s := range state {
+		colors[i] = req.faceletColor(stickers[s].Home)
+	}
+
+	c := newCanvas(req.Format, req.Size, req.Size, visualCubeViewBox, req.Background)
+	body := baseMaterialColor(gltfDoc)
+
+	var project func(vector) point
+	switch req.View {
+	case visualCubeViewPlan:
+		project = planProjection
+		drawPlan(c, colors, body)
+	default:
+		project = perspectiveProjection(req.Rotation)
+		drawPerspective(c, colors, body, req.Rotation, req.View == visualCubeViewTrans)
+	}
+
+	for _, a := range req.Arrows {
+		for _, p := range arrowPolygons(a, project) {
+			c.polygon(p, a.Color)
+		}
+	}
+
+	return c.encode()
+}
+
+// faceletColor 完成状態での位置が home のステッカーの色を取得する
+func (req *visualCubeRequest) faceletColor(home int) color.NRGBA {
+	if home < len(req.Colors) {
+		return req.Colors[home]
+	}
+	if req.Mask[home] {
+		return colorMask
+	}
+	if req.Definition != "" {
+		switch code := req.Definition[home]; code {
+		case 'n':
+			return colorMask
+		case 't':
+			return colorTransparent
+		default:
+			return req.Scheme[strings.IndexByte("urfdlb", code)]
+		}
+	}
+	return req.Scheme[home/9]
+}
+
+// faceletQuad ファセットの頂点を取得する。座標系はキューブの中心を原点とし、1 辺を 1 とする
+func faceletQuad(facelet int, inset float64) []vector {
+	axes := faceAxes[facelet/9]
+	return quadAround(faceletCenter(facelet), axes.right, axes.down, (1-inset)/6, (1-inset)/6)
+}
+
+// faceletCenter ファセットの中心の座標を取得する
+func faceletCenter(facelet int) vector {
+	axes := faceAxes[facelet/9]
+	col, row := float64(facelet%3-1), float64(facelet%9/3-1)
+	return add(scaled(axes.normal, 0.5), add(scaled(axes.right, col/3), scaled(axes.down, row/3)))
+}
+
+// quadAround 中心と 2 方向の半径から四角形の頂点を取得する
+func quadAround(center, right, down vector, halfWidth, halfHeight float64) []vector {
+	r, d := scaled(right, halfWidth), scaled(down, halfHeight)
+	return []vector{
+		add(center, add(scaled(r, -1), scaled(d, -1))),
+		add(center, add(r, scaled(d, -1))),
+		add(center, add(r, d)),
+		add(center, add(scaled(r, -1), d)),
+	}
+}
+
+// perspectiveProjection 回転後のキューブを透視投影する関数を取得する
+func perspectiveProjection(rotation matrix) func(vector) point {
+	return func(v vector) point {
+		p := rotation.apply(v)
+		k := visualCubeDistance / (visualCubeDistance - p[2]) * visualCubeScale
+		return point{p[0] * k, -p[1] * k}
+	}
+}
+
+// drawPerspective キューブを透視投影で描画する。transparent の場合は裏側の面も透かして描画する
+func drawPerspective(c canvas, colors [faceletCount]color.NRGBA, body color.NRGBA, rotation matrix, transparent bool) {
+	project := perspectiveProjection(rotation)
+	camera := vector{0, 0, visualCubeDistance}
+
+	var front, back []Face
+	for f, axes := range faceAxes {
+		normal := rotation.apply(axes.normal)
+		center := rotation.apply(scaled(axes.normal, 0.5))
+		if dot(normal, add(camera, scaled(center, -1))) > 0 {
+			front = append(front, Face(f))
+		} else {
+			back = append(back, Face(f))
+		}
+	}
+
+	if transparent {
+		for _, f := range back {
+			drawFace(c, colors, body, f, project, 0xff)
+		}
+		for _, f := range front {
+			drawFace(c, colors, body, f, project, 0x80)
+		}
+		return
+	}
+	for _, f := range front {
+		drawFace(c, colors, body, f, project, 0xff)
+	}
+}
+
+// drawFace 面の本体とステッカーを描画する
+func drawFace(c canvas, colors [faceletCount]color.NRGBA, body color.NRGBA, face Face, project func(vector) point, alpha uint8) {
+	axes := faceAxes[face]
+	c.polygon(projectAll(quadAround(scaled(axes.normal, 0.5), axes.right, axes.down, 0.5, 0.5), project), withAlpha(body, alpha))
+	for i := 0; i < 9; i++ {
+		f := int(face)*9 + i
+		c.polygon(projectAll(faceletQuad(f, stickerInset), project), withAlpha(colors[f], alpha))
+	}
+}
+
+// planProjection U 面を真上から見て、U 層の側面を周囲に広げて投影する
+func planProjection(v vector) point {
+	k := (1 + (0.5-v[1])*planFold) * planScale
+	return point{v[0] * k, v[2] * k}
+}
+
+// drawPlan U 面と U 層の側面のステッカーを平面図で描画する
+func drawPlan(c canvas, colors [faceletCount]color.NRGBA, body color.NRGBA) {
+	drawFace(c, colors, body, FaceU, planProjection, 0xff)
+
+	for _, face := range []Face{FaceR, FaceF, FaceL, FaceB} {
+		axes := faceAxes[face]
+		center := add(scaled(axes.normal, 0.5), scaled(axes.down, -1.0/3))
+		c.polygon(projectAll(quadAround(center, axes.right, axes.down, 0.5, 1.0/6), planProjection), body)
+		for i := 0; i < 3; i++ {
+			f := int(face)*9 + i
+			c.polygon(projectAll(faceletQuad(f, stickerInset), planProjection), colors[f])
+		}
+	}
+}
+
+// arrowPolygons 矢印を描画する多角形を取得する
+func arrowPolygons(a arrow, project func(vector) point) [][]point {
+	const (
+		width      = 0.035
+		headLength = 0.09
+		headWidth  = 0.09
+		segments   = 16
+	)
+
+	start, end := project(faceletCenter(a.From)), project(faceletCenter(a.To))
+	control := point{(start.X + end.X) / 2, (start.Y + end.Y) / 2}
+	if a.Via >= 0 {
+		// 曲線の中間点が制御用のファセットの中心を通るように制御点を決める
+		via := project(faceletCenter(a.Via))
+		control = point{
+			control.X + (via.X-control.X)*2*a.Influence,
+			control.Y + (via.Y-control.Y)*2*a.Influence,
+		}
+	}
+
+	var path []point
+	margin := (1 - a.Scale) / 2
+	for i := 0; i <= segments; i++ {
+		t := margin + (1-2*margin)*float64(i)/segments
+		path = append(path, point{
+			(1-t)*(1-t)*start.X + 2*(1-t)*t*control.X + t*t*end.X,
+			(1-t)*(1-t)*start.Y + 2*(1-t)*t*control.Y + t*t*end.Y,
+		})
+	}
+
+	tip := path[len(path)-1]
+	dx, dy := tip.X-path[len(path)-2].X, tip.Y-path[len(path)-2].Y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		return nil
+	}
+	dx, dy = dx/length, dy/length
+	base := point{tip.X - dx*headLength, tip.Y - dy*headLength}
+
+	// 矢じりと重ならないように軸を短くする
+	for len(path) > 2 && math.Hypot(path[len(path)-2].X-tip.X, path[len(path)-2].Y-tip.Y) < headLength {
+		path = path[:len(path)-1]
+	}
+	path[len(path)-1] = base
+
+	return [][]point{
+		strokePolygon(path, width),
+		{
+			tip,
+			{base.X - dy*headWidth/2, base.Y + dx*headWidth/2},
+			{base.X + dy*headWidth/2, base.Y - dx*headWidth/2},
+		},
+	}
+}
+
+// strokePolygon 折れ線を太さ width の 1 つの多角形に変換する
+func strokePolygon(path []point, width float64) []point {
+	left := make([]point, 0, len(path))
+	right := make([]point, 0, len(path))
+	for i, p := range path {
+		a, b := p, p
+		if i > 0 {
+			a = path[i-1]
+		}
+		if i < len(path)-1 {
+			b = path[i+1]
+		}
+		dx, dy := b.X-a.X, b.Y-a.Y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*width/2, dx/length*width/2
+		left = append(left, point{p.X + nx, p.Y + ny})
+		right = append(right, point{p.X - nx, p.Y - ny})
+	}
+
+	for i := len(right) - 1; i >= 0; i-- {
+		left = append(left, right[i])
+	}
+	return left
+}
+
+// projectAll 頂点をまとめて投影する
+func projectAll(vertices []vector, project func(vector) point) []point {
+	points := make([]point, len(vertices))
+	for i, v := range vertices {
+		points[i] = project(v)
+	}
+	return points
+}
+
+// withAlpha 色の不透明度に alpha を掛ける
+func withAlpha(c color.NRGBA, alpha uint8) color.NRGBA {
+	c.A = uint8(uint16(c.A) * uint16(alpha) / 0xff)
+	return c
+}
+
+// parseVisualCubeAlg VisualCube の alg 形式 (空白なしも可) の文字列を回転記号のスライスに変換する
+func parseVisualCubeAlg(s string) ([]string, error) {
+	var alg []string
+	rest := strings.Join(strings.Fields(s), "")
+	for rest != "" {
+		loc := visualCubeAlgRegex.FindStringIndex(rest)
+		if loc == nil || loc[0] != 0 {
+			return nil, fmt.Errorf(`unsupported move at "%s": alg must only use face turns "U D F B L R" with "'" or "2"`, rest)
+		}
+		move := rest[:loc[1]]
+		if strings.Contains(move, "2") {
+			move = move[:1] + "2"
+		}
+		alg = append(alg, move)
+		rest = rest[loc[1]:]
+	}
+	return alg, nil
+}
+
+// invertAlg 回転記号のスライスを逆順・逆回転にする
+func invertAlg(alg []string) []string {
+	inverted := make([]string, len(alg))
+	for i, move := range alg {
+		switch {
+		case strings.HasSuffix(move, "'"):
+			move = strings.TrimSuffix(move, "'")
+		case !strings.HasSuffix(move, "2"):
+			move += "'"
+		}
+		inverted[len(alg)-1-i] = move
+	}
+	return inverted
+}
+
+// parseVisualCubeRotation VisualCube の r パラメーター (y45x-34 など) を回転行列に変換する
+func parseVisualCubeRotation(s string) (matrix, error) {
+	m := identityMatrix
+	if visualCubeRotationRegex.ReplaceAllString(s, "") != "" {
+		return m, fmt.Errorf("r must be the following pattern: \"([xyz]-?[0-9]+)*\": %s", s)
+	}
+
+	for _, match := range visualCubeRotationRegex.FindAllStringSubmatch(s, -1) {
+		degree, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return m, err
+		}
+		// VisualCube の角度は glTF の座標系 (右手系) と逆回りになる
+		m = axisRotation(int(match[1][0]-'x'), -degree*math.Pi/180).mul(m)
+	}
+
+	return m, nil
+}
+
+// parseArrows VisualCube の arw パラメーター (U0U2,U2U8-s8-i5-red など) を矢印に変換する
+func parseArrows(s string) ([]arrow, error) {
+	var arrows []arrow
+	for _, def := range strings.Split(s, ",") {
+		if def == "" {
+			continue
+		}
+
+		parts := strings.Split(def, "-")
+		if !arrowFaceletsRegex.MatchString(parts[0]) {
+			return nil, fmt.Errorf("arrow must start with 2 or 3 facelets like \"U0U2\": %s", def)
+		}
+
+		a := arrow{Via: -1, Scale: 1, Influence: 1, Color: colorCodes['d']}
+		facelets := make([]int, 0, 3)
+		for i := 0; i < len(parts[0]); i += 2 {
+			facelets = append(facelets, strings.IndexByte("URFDLB", parts[0][i])*9+int(parts[0][i+1]-'0'))
+		}
+		a.From, a.To = facelets[0], facelets[1]
+		if len(facelets) == 3 {
+			a.Via = facelets[2]
+		}
+
+		for _, option := range parts[1:] {
+			// U0U2- や U0U2--s8 のような空のオプションは読み飛ばす
+			if option == "" {
+				continue
+			}
+			if v, err := strconv.Atoi(option[1:]); err == nil && (option[0] == 's' || option[0] == 'i') {
+				if option[0] == 's' {
+					a.Scale = math.Max(0, math.Min(20, float64(v))) / 10
+				} else {
+					a.Influence = math.Max(0, math.Min(20, float64(v))) / 10
+				}
+				continue
+			}
+			c, err := parseColor(option)
+			if err != nil {
+				return nil, err
+			}
+			a.Color = c
+		}
+
+		arrows = append(arrows, a)
+	}
+	return arrows, nil
+}
+
+// parseScheme VisualCube の sch パラメーター (6 文字の色コード、またはカンマ区切りの 6 色) を面ごとの色に変換する
+func parseScheme(s string) ([6]color.NRGBA, error) {
+	var scheme [6]color.NRGBA
+
+	colors := strings.Split(s, ",")
+	if len(colors) == 1 && len(s) == 6 {
+		colors = strings.Split(s, "")
+	}
+	if len(colors) != 6 {
+		return scheme, errors.New("sch must have 6 colors in order of U R F D L B")
+	}
+
+	for i, c := range colors {
+		var err error
+		if scheme[i], err = parseColor(c); err != nil {
+			return scheme, err
+		}
+	}
+
+	return scheme, nil
+}
+
+// parseFaceletColors VisualCube の fc パラメーター (色コードの列) をファセットごとの色に変換する
+func parseFaceletColors(s string) ([]color.NRGBA, error) {
+	if len(s) > faceletCount {
+		return nil, fmt.Errorf("fc must be at most %d color codes", faceletCount)
+	}
+
+	colors := make([]color.NRGBA, len(s))
+	for i := range s {
+		c, ok := colorCodes[s[i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown color code in fc: %c", s[i])
+		}
+		colors[i] = c
+	}
+	return colors, nil
+}
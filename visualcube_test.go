@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVisualCubeAlg(t *testing.T) {
+	tests := []struct {
+		alg     string
+		want    []string
+		wantErr bool
+	}{
+		{alg: "R U R' U'", want: []string{"R", "U", "R'", "U'"}},
+		{alg: "RUR'U'", want: []string{"R", "U", "R'", "U'"}},
+		{alg: "R2' U'2 F2", want: []string{"R2", "U2", "F2"}},
+		{alg: "R U x", wantErr: true},
+		{alg: "M2 U", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseVisualCubeAlg(tt.alg)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: unexpected error: %v", tt.alg, err)
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: alg must be %v, actual: %v", tt.alg, tt.want, got)
+		}
+	}
+}
+
+func TestInvertAlg(t *testing.T) {
+	got := invertAlg([]string{"R", "U2", "F'"})
+	want := []string{"F", "U2", "R'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("alg must be %v, actual: %v", want, got)
+	}
+}
+
+func TestStageMask(t *testing.T) {
+	tests := []struct {
+		stage string
+		// マスクしないファセットの数
+		visible int
+	}{
+		{stage: "f2l", visible: 9 + 4*6},
+		{stage: "ll", visible: 9 + 4*3},
+		{stage: "oll", visible: 9},
+		{stage: "cross", visible: 5 + 4*2},
+		{stage: "cross-x2", visible: 5 + 4*2},
+		{stage: "pll", visible: 9 + 4*3},
+		{stage: "eoline", visible: 3 + 2*2 + 10},
+		{stage: "UFR,UF,D", visible: 3 + 2 + 1},
+		{stage: "f2l,UFR", visible: 9 + 4*6 + 3},
+	}
+
+	for _, tt := range tests {
+		mask, err := stageMask(tt.stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		visible := 0
+		for _, m := range mask {
+			if !m {
+				visible++
+			}
+		}
+		if visible != tt.visible {
+			t.Errorf("%s: visible facelets must be %d, actual: %d", tt.stage, tt.visible, visible)
+		}
+	}
+
+	mask, err := stageMask("cross-x2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mask[4] || !mask[int(FaceD)*9+4] {
+		t.Error("cross-x2 must show the U center and mask the D center")
+	}
+}
+
+func TestParseArrows(t *testing.T) {
+	tests := []struct {
+		arw     string
+		scale   float64
+		wantErr bool
+	}{
+		{arw: "U0U2", scale: 1},
+		{arw: "U0U2-s8", scale: 0.8},
+		{arw: "U0U2-", scale: 1},
+		{arw: "U0U2--s8", scale: 0.8},
+		{arw: "U0U2-nocolor", wantErr: true},
+		{arw: "U0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		arrows, err := parseArrows(tt.arw)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("%s: unexpected error: %v", tt.arw, err)
+		}
+		if !tt.wantErr && arrows[0].Scale != tt.scale {
+			t.Errorf("%s: scale must be %g, actual: %g", tt.arw, tt.scale, arrows[0].Scale)
+		}
+	}
+}